@@ -0,0 +1,125 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// ToggleBookmark adds a bookmark on line row, or removes the one already
+// there. Bookmarks are marks at the start of their line, so they move with
+// the line as text is edited above it.
+func (b *Buffer) ToggleBookmark(row int) {
+	for i, m := range b.bookmarks {
+		if r, _ := m.Pos(); r == row {
+			b.RemoveMark(m)
+			b.bookmarks = append(b.bookmarks[:i], b.bookmarks[i+1:]...)
+			return
+		}
+	}
+	// StickRight keeps the bookmark with the line when a newline is
+	// inserted at its start
+	b.bookmarks = append(b.bookmarks, b.NewMark(b.PosToOffset(row, 0), StickRight))
+}
+
+// Bookmarks returns the bookmarked lines in order. Bookmarks whose lines were
+// joined by a deletion end up on the same line and are merged.
+func (b *Buffer) Bookmarks() []int {
+	sort.Slice(b.bookmarks, func(i, j int) bool {
+		return b.bookmarks[i].Offset() < b.bookmarks[j].Offset()
+	})
+
+	var rows []int
+	kept := b.bookmarks[:0]
+	for _, m := range b.bookmarks {
+		row, _ := m.Pos()
+		if len(rows) > 0 && rows[len(rows)-1] == row {
+			b.RemoveMark(m)
+			continue
+		}
+		rows = append(rows, row)
+		kept = append(kept, m)
+	}
+	clear(b.bookmarks[len(kept):])
+	b.bookmarks = kept
+	return rows
+}
+
+// HasBookmark reports whether line row is bookmarked.
+func (b *Buffer) HasBookmark(row int) bool {
+	for _, m := range b.bookmarks {
+		if r, _ := m.Pos(); r == row {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBookmarks replaces all bookmarks with ones on the given lines. Lines
+// past the end of the text are dropped.
+func (b *Buffer) SetBookmarks(rows []int) {
+	for _, m := range b.bookmarks {
+		b.RemoveMark(m)
+	}
+	b.bookmarks = nil
+	for _, row := range rows {
+		if row >= 0 && row < b.LineCount() && !b.HasBookmark(row) {
+			b.ToggleBookmark(row)
+		}
+	}
+}
+
+// NextBookmark returns the first bookmarked line after row, wrapping around
+// to the top. ok is false if there are no bookmarks.
+func (b *Buffer) NextBookmark(row int) (next int, ok bool) {
+	rows := b.Bookmarks()
+	if len(rows) == 0 {
+		return 0, false
+	}
+	for _, r := range rows {
+		if r > row {
+			return r, true
+		}
+	}
+	return rows[0], true
+}
+
+// PrevBookmark returns the last bookmarked line before row, wrapping around
+// to the bottom. ok is false if there are no bookmarks.
+func (b *Buffer) PrevBookmark(row int) (prev int, ok bool) {
+	rows := b.Bookmarks()
+	if len(rows) == 0 {
+		return 0, false
+	}
+	for i := len(rows) - 1; i >= 0; i-- {
+		if rows[i] < row {
+			return rows[i], true
+		}
+	}
+	return rows[len(rows)-1], true
+}
+
+// BookmarkEntry is one row of the bookmark list.
+type BookmarkEntry struct {
+	Path string // Absolute path of the file
+	Row  int
+	Text string // Text of the line, only known for the open file
+}
+
+// BookmarkList lists the bookmarks of the open file b, saved at filePath,
+// followed by those remembered in the session for other files.
+func BookmarkList(b *Buffer, filePath string, session *Session) []BookmarkEntry {
+	abs, _ := filepath.Abs(filePath)
+	var entries []BookmarkEntry
+	for _, row := range b.Bookmarks() {
+		entries = append(entries, BookmarkEntry{Path: abs, Row: row, Text: b.Line(row)})
+	}
+	for _, f := range session.Files {
+		if f.Path == abs {
+			continue
+		}
+		for _, row := range f.Bookmarks {
+			entries = append(entries, BookmarkEntry{Path: f.Path, Row: row})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBookmarksFollowEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(b *Buffer)
+		want []int
+	}{
+		{"newline at the start of the line", func(b *Buffer) { b.Insert(b.PosToOffset(1, 0), "\n") }, []int{2, 4}},
+		{"newline at the end of the line before", func(b *Buffer) { b.Insert(b.PosToOffset(0, 1), "\n") }, []int{2, 4}},
+		{"lines deleted above", func(b *Buffer) { b.Delete(Range{Start: 0, End: b.PosToOffset(1, 0)}) }, []int{0, 2}},
+		{"bookmarked lines joined", func(b *Buffer) { b.Delete(Range{Start: b.PosToOffset(1, 1), End: b.PosToOffset(3, 0)}) }, []int{1}},
+		{"bookmarked line deleted", func(b *Buffer) { b.Delete(Range{Start: b.PosToOffset(1, 0), End: b.PosToOffset(2, 0)}) }, []int{1, 2}},
+		{"undone", func(b *Buffer) { b.Insert(0, "\n\n"); b.Undo() }, []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load("a\nb\nc\nd")
+			b.ToggleBookmark(1)
+			b.ToggleBookmark(3)
+			tt.edit(b)
+			if got := b.Bookmarks(); !slices.Equal(got, tt.want) {
+				t.Errorf("Bookmarks() = %v, want %v", got, tt.want)
+			}
+			if len(b.marks) != len(tt.want) {
+				t.Errorf("%d marks left for %d bookmarks", len(b.marks), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestBookmarkJumps(t *testing.T) {
+	b := NewBuffer()
+	b.Load("a\nb\nc\nd\ne")
+	if _, ok := b.NextBookmark(0); ok {
+		t.Error("NextBookmark found a bookmark in a buffer without any")
+	}
+	b.SetBookmarks([]int{3, 1, 1, -1, 99})
+	if got := b.Bookmarks(); !slices.Equal(got, []int{1, 3}) {
+		t.Fatalf("Bookmarks() = %v after SetBookmarks, want [1 3]", got)
+	}
+	tests := []struct {
+		row, next, prev int
+	}{
+		{0, 1, 3},
+		{1, 3, 3},
+		{2, 3, 1},
+		{3, 1, 1},
+		{4, 1, 3},
+	}
+	for _, tt := range tests {
+		if got, ok := b.NextBookmark(tt.row); !ok || got != tt.next {
+			t.Errorf("NextBookmark(%d) = %d, %v, want %d", tt.row, got, ok, tt.next)
+		}
+		if got, ok := b.PrevBookmark(tt.row); !ok || got != tt.prev {
+			t.Errorf("PrevBookmark(%d) = %d, %v, want %d", tt.row, got, ok, tt.prev)
+		}
+	}
+
+	b.ToggleBookmark(3)
+	if got := b.Bookmarks(); !slices.Equal(got, []int{1}) || b.HasBookmark(3) {
+		t.Errorf("Bookmarks() = %v after toggling line 3 off", got)
+	}
+}
+
+func TestBookmarkList(t *testing.T) {
+	b := NewBuffer()
+	b.Load("first\nsecond\nthird")
+	b.SetBookmarks([]int{0, 2})
+	s := &Session{}
+	s.SetBookmarks("open.txt", []int{1}) // Stale, the buffer has the current ones
+	s.SetBookmarks("other.txt", []int{4, 7})
+
+	open, _ := filepath.Abs("open.txt")
+	other, _ := filepath.Abs("other.txt")
+	want := []BookmarkEntry{
+		{Path: open, Row: 0, Text: "first"},
+		{Path: open, Row: 2, Text: "third"},
+		{Path: other, Row: 4},
+		{Path: other, Row: 7},
+	}
+	if got := BookmarkList(b, "open.txt", s); !slices.Equal(got, want) {
+		t.Errorf("BookmarkList = %v, want %v", got, want)
+	}
+}
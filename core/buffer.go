@@ -0,0 +1,408 @@
+// Package core is the editing engine of the editor: the text buffer with its
+// undo history, marks, cursors, editing commands, search and file encodings.
+// It has no user interface of its own, so it can be embedded in other tools
+// and tested without a display; the SDL front end in the main package is one
+// client of it.
+package core
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Range is a half-open span [Start, End) of byte offsets into a Buffer.
+type Range struct {
+	Start, End int
+}
+
+type Buffer struct {
+	table    *PieceTable
+	lines    *lineIndex
+	History  *UndoTree
+	Encoding Encoding  // How the text is stored on disk
+	Words    WordRules // Which characters make up words, from the language of the file
+	readOnly bool
+
+	version      int
+	snapshot     *Snapshot // Last snapshot taken, reused until the next change
+	listeners    []listener
+	nextListener int
+
+	marks      []*Mark
+	namedMarks map[string]*Mark
+	bookmarks  []*Mark
+
+	groupDepth int            // > 0 while edits are collected into one undo step
+	cursors    *CursorManager // Cursors saved with each undo step, if any
+	txn        *transaction   // Open transaction, if any
+}
+
+func NewBuffer() *Buffer {
+	return &Buffer{
+		table:   NewPieceTable(""),
+		lines:   newLineIndex(""),
+		History: NewUndoTree(),
+	}
+}
+
+// String returns the full text of the buffer.
+func (b *Buffer) String() string {
+	return b.table.String()
+}
+
+// Len returns the length of the buffer in bytes.
+func (b *Buffer) Len() int {
+	return b.table.Len()
+}
+
+// Slice returns the text covered by r.
+func (b *Buffer) Slice(r Range) string {
+	return b.table.Slice(r.Start, r.End)
+}
+
+// Insert inserts text at the byte offset pos.
+func (b *Buffer) Insert(pos int, text string) {
+	if text == "" {
+		return
+	}
+	b.record(Edit{Pos: b.table.clamp(pos), Inserted: text})
+}
+
+// Delete removes the text covered by r.
+func (b *Buffer) Delete(r Range) {
+	if r.Start >= r.End {
+		return
+	}
+	b.record(Edit{Pos: b.table.clamp(r.Start), Deleted: b.Slice(r)})
+}
+
+// Load replaces the whole text and starts a fresh undo history, for
+// opening a file. Line endings are detected and stripped to plain \n;
+// TextWithLineEndings puts them back.
+func (b *Buffer) Load(content string) {
+	old := Range{Start: 0, End: b.Len()}
+	content, crlf := splitLineEndings(content)
+	b.pinCursors()
+	b.table = NewPieceTable(content)
+	b.lines = newLineIndex(content)
+	b.lines.setEndings(append(crlf, mostlyCRLF(crlf)))
+	b.snapshot = nil
+	b.History = NewUndoTree()
+	b.shiftMarks(0, old.End, len(content))
+	b.followCursors()
+	b.notify(old, content)
+}
+
+// SetContent replaces the whole text as a single undoable change.
+func (b *Buffer) SetContent(content string) {
+	b.record(Edit{Pos: 0, Deleted: b.String(), Inserted: content})
+}
+
+// record applies an edit and adds it to the undo history. Edits made after
+// an undo start a new branch of the history.
+func (b *Buffer) record(edit Edit) {
+	if b.readOnly || edit.kind() == editEndings && edit.InsertedCRLF == nil {
+		return
+	}
+	before := b.cursorState()
+	edit = b.apply(edit)
+	if b.txn != nil {
+		b.txn.edits = append(b.txn.edits, edit)
+	}
+
+	now := time.Now()
+	if node := b.History.open(); node != nil && (b.groupDepth > 0 || canCoalesce(node, edit, now)) {
+		b.History.extend(edit, now)
+		return
+	}
+	b.SealUndo()
+	b.History.push(UndoEntry{Edits: []Edit{edit}, CursorsBefore: before, kind: edit.kind()}, now)
+}
+
+// SealUndo ends the current undo step, so the next edit starts a new one,
+// and saves the cursors as they are now as the state after the step. Call it
+// before the cursor jumps somewhere else.
+func (b *Buffer) SealUndo() {
+	if node := b.History.open(); node != nil {
+		node.Entry.CursorsAfter = b.cursorState()
+		node.Entry.sealed = true
+	}
+}
+
+// TrackCursors makes undo and redo save and restore the cursors of cm.
+func (b *Buffer) TrackCursors(cm *CursorManager) {
+	b.cursors = cm
+}
+
+func (b *Buffer) cursorState() CursorState {
+	if b.cursors == nil {
+		return CursorState{}
+	}
+	return b.cursors.Snapshot()
+}
+
+// pinCursors and followCursors carry the tracked cursors across an edit on
+// their marks.
+func (b *Buffer) pinCursors() {
+	if b.cursors != nil {
+		b.cursors.pin(b)
+	}
+}
+
+func (b *Buffer) followCursors() {
+	if b.cursors != nil {
+		b.cursors.follow()
+	}
+}
+
+func (b *Buffer) restoreCursors(state CursorState) {
+	if b.cursors != nil {
+		b.cursors.Restore(state)
+	}
+}
+
+// beginUndoGroup starts collecting edits into a single undo step until the
+// matching endUndoGroup. Groups may be nested.
+func (b *Buffer) beginUndoGroup() {
+	if b.groupDepth == 0 {
+		b.SealUndo()
+	}
+	b.groupDepth++
+}
+
+// endUndoGroup closes a group opened by beginUndoGroup. Typing right after
+// the group may still be merged into it, so a replaced selection and the
+// text typed over it undo together.
+func (b *Buffer) endUndoGroup() {
+	if b.groupDepth > 0 {
+		b.groupDepth--
+	}
+}
+
+// apply performs the replacement described by edit on the text and moves
+// the marks and cursors along with it. It returns edit with the line endings
+// and the piece table spans it removed and inserted filled in.
+func (b *Buffer) apply(edit Edit) Edit {
+	if edit.kind() == editEndings {
+		b.lines.setEndings(edit.InsertedCRLF) // The text stays the same
+		return edit
+	}
+	b.pinCursors()
+	if edit.deletedSpans == nil {
+		edit.deletedSpans = b.table.spans(edit.Pos, edit.Pos+len(edit.Deleted))
+	}
+	b.table.Delete(edit.Pos, edit.Pos+len(edit.Deleted))
+	edit.DeletedCRLF = b.lines.delete(edit.Pos, edit.Pos+len(edit.Deleted))
+	if edit.insertedSpans != nil {
+		b.table.insertSpans(edit.Pos, edit.insertedSpans)
+	} else {
+		b.table.Insert(edit.Pos, edit.Inserted)
+		edit.insertedSpans = b.table.spans(edit.Pos, edit.Pos+len(edit.Inserted))
+	}
+	edit.InsertedCRLF = b.lines.insert(edit.Pos, edit.Inserted, edit.InsertedCRLF)
+	b.snapshot = nil
+	b.shiftMarks(edit.Pos, len(edit.Deleted), len(edit.Inserted))
+	b.followCursors()
+	b.notify(Range{Start: edit.Pos, End: edit.Pos + len(edit.Deleted)}, edit.Inserted)
+	return edit
+}
+
+// Undo moves back to the state before the current one.
+func (b *Buffer) Undo() bool {
+	if b.readOnly {
+		return false
+	}
+	b.SealUndo()
+	if b.History.Current == b.History.Root {
+		return false
+	}
+	b.undoStep()
+	return true
+}
+
+// Redo moves forward along the branch that was undone most recently.
+func (b *Buffer) Redo() bool {
+	if b.readOnly {
+		return false
+	}
+	b.SealUndo()
+	current := b.History.Current
+	if len(current.Children) == 0 {
+		return false
+	}
+	b.redoStep(current.Children[current.redo])
+	return true
+}
+
+// StepBack moves to the state created just before the current one, whichever
+// branch it is on, like vim's g-.
+func (b *Buffer) StepBack() bool {
+	if b.readOnly {
+		return false
+	}
+	b.SealUndo()
+	i := b.History.IndexOf(b.History.Current)
+	if i <= 0 {
+		return false
+	}
+	b.GoTo(b.History.nodes[i-1])
+	return true
+}
+
+// StepForward moves to the state created just after the current one, like
+// vim's g+.
+func (b *Buffer) StepForward() bool {
+	if b.readOnly {
+		return false
+	}
+	b.SealUndo()
+	i := b.History.IndexOf(b.History.Current)
+	if i < 0 || i+1 >= len(b.History.nodes) {
+		return false
+	}
+	b.GoTo(b.History.nodes[i+1])
+	return true
+}
+
+// SwitchBranch moves to a sibling of the current state, delta branches to
+// the right (or left when negative).
+func (b *Buffer) SwitchBranch(delta int) bool {
+	if b.readOnly {
+		return false
+	}
+	b.SealUndo()
+	current := b.History.Current
+	if current.Parent == nil || len(current.Parent.Children) < 2 {
+		return false
+	}
+	siblings := current.Parent.Children
+	i := (childIndex(current) + delta) % len(siblings)
+	if i < 0 {
+		i += len(siblings)
+	}
+	b.GoTo(siblings[i])
+	return true
+}
+
+// Earlier moves to the text as it was d before the current state, like
+// vim's :earlier.
+func (b *Buffer) Earlier(d time.Duration) bool {
+	if b.readOnly {
+		return false
+	}
+	b.SealUndo()
+	target := b.History.latestBefore(b.History.Current.Time.Add(-d))
+	if target == b.History.Current {
+		return false
+	}
+	b.GoTo(target)
+	return true
+}
+
+// Later moves to the text as it was d after the current state, like vim's
+// :later.
+func (b *Buffer) Later(d time.Duration) bool {
+	if b.readOnly {
+		return false
+	}
+	b.SealUndo()
+	target := b.History.latestBefore(b.History.Current.Time.Add(d))
+	if target.Seq <= b.History.Current.Seq {
+		return false
+	}
+	b.GoTo(target)
+	return true
+}
+
+// GoTo moves to any state in the history by undoing up to the closest
+// common ancestor and redoing down to target.
+func (b *Buffer) GoTo(target *UndoNode) {
+	if b.readOnly {
+		return
+	}
+	b.SealUndo()
+	up, down := b.History.path(b.History.Current, target)
+	for range up {
+		b.undoStep()
+	}
+	for _, node := range down {
+		b.redoStep(node)
+	}
+}
+
+// undoStep reverts the current node and moves to its parent. Listeners see
+// the whole step as one change.
+func (b *Buffer) undoStep() {
+	b.Begin()
+	defer b.Commit()
+	node := b.History.Current
+	for i := len(node.Entry.Edits) - 1; i >= 0; i-- {
+		node.Entry.Edits[i] = b.apply(node.Entry.Edits[i].invert()).invert() // Keeps the spans of edits loaded from disk
+	}
+	b.restoreCursors(node.Entry.CursorsBefore)
+	node.Parent.redo = childIndex(node)
+	b.History.Current = node.Parent
+}
+
+// redoStep applies child, which must be a child of the current node, and
+// moves to it. Listeners see the whole step as one change.
+func (b *Buffer) redoStep(child *UndoNode) {
+	b.Begin()
+	defer b.Commit()
+	for i, edit := range child.Entry.Edits {
+		child.Entry.Edits[i] = b.apply(edit)
+	}
+	b.restoreCursors(child.Entry.CursorsAfter)
+	child.Parent.redo = childIndex(child)
+	b.History.Current = child
+}
+
+// LineCount returns the number of lines. An empty buffer has one empty line.
+func (b *Buffer) LineCount() int {
+	return b.lines.count()
+}
+
+// lineRange returns the byte range of line i, without its newline.
+func (b *Buffer) lineRange(i int) Range {
+	return b.lines.lineRange(i, b.Len())
+}
+
+// Line returns the text of line i without its newline.
+func (b *Buffer) Line(i int) string {
+	return b.Slice(b.lineRange(i))
+}
+
+// LineLen returns the length of line i in columns (runes).
+func (b *Buffer) LineLen(i int) int {
+	return utf8.RuneCountInString(b.Line(i))
+}
+
+// PosToOffset converts a row and rune column into a byte offset. Positions
+// past the end of a line or of the buffer are clamped.
+func (b *Buffer) PosToOffset(row, col int) int {
+	if row < 0 {
+		return 0
+	}
+	if row >= b.LineCount() {
+		return b.Len()
+	}
+	r := b.lineRange(row)
+	if col <= 0 {
+		return r.Start
+	}
+	line := b.Slice(r)
+	offset := 0
+	for c := 0; c < col && offset < len(line); c++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return r.Start + offset
+}
+
+// OffsetToPos converts a byte offset into a row and rune column.
+func (b *Buffer) OffsetToPos(offset int) (int, int) {
+	offset = b.table.clamp(offset)
+	row := b.lines.lineAt(offset)
+	start := b.lines.start(row)
+	return row, utf8.RuneCountInString(b.Slice(Range{Start: start, End: offset}))
+}
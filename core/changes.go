@@ -0,0 +1,55 @@
+package core
+
+// ChangeEvent describes one change to a Buffer: the text that was in Range,
+// in offsets from before the change, was replaced by Text. Version is the
+// buffer version after the change.
+type ChangeEvent struct {
+	Range   Range
+	Text    string
+	Version int
+}
+
+// ChangeListener is called after every change to a Buffer, including undo
+// and redo. All edits of a transaction arrive as a single change.
+type ChangeListener func(ChangeEvent)
+
+type listener struct {
+	id int
+	fn ChangeListener
+}
+
+// Subscribe registers fn to be told about every change to the buffer. The
+// returned function removes it again.
+func (b *Buffer) Subscribe(fn ChangeListener) (unsubscribe func()) {
+	b.nextListener++
+	id := b.nextListener
+	b.listeners = append(b.listeners, listener{id: id, fn: fn})
+	return func() {
+		for i, l := range b.listeners {
+			if l.id == id {
+				b.listeners = append(b.listeners[:i:i], b.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Version returns a number that goes up with every change to the buffer.
+func (b *Buffer) Version() int {
+	return b.version
+}
+
+// notify bumps the version and tells every listener about the change.
+// Inside a transaction the change is only remembered, and Commit reports
+// all of them at once.
+func (b *Buffer) notify(r Range, text string) {
+	if b.txn != nil {
+		b.txn.touch(r.Start, r.End-r.Start, len(text))
+		return
+	}
+	b.version++
+	event := ChangeEvent{Range: r, Text: text, Version: b.version}
+	for _, l := range b.listeners {
+		l.fn(event)
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChangeEvents(t *testing.T) {
+	b := NewBuffer()
+	b.Load("hello")
+	var events []ChangeEvent
+	unsubscribe := b.Subscribe(func(e ChangeEvent) { events = append(events, e) })
+	var others int
+	b.Subscribe(func(ChangeEvent) { others++ })
+
+	start := b.Version()
+	b.Insert(5, " world")
+	b.Delete(Range{Start: 0, End: 1})
+	b.SetContent("bye")
+	b.Undo()
+	b.Redo()
+	want := []ChangeEvent{
+		{Range: Range{Start: 5, End: 5}, Text: " world", Version: start + 1},
+		{Range: Range{Start: 0, End: 1}, Text: "", Version: start + 2},
+		{Range: Range{Start: 0, End: 10}, Text: "bye", Version: start + 3},
+		{Range: Range{Start: 0, End: 3}, Text: "ello world", Version: start + 4},
+		{Range: Range{Start: 0, End: 10}, Text: "bye", Version: start + 5},
+	}
+	if !slices.Equal(events, want) {
+		t.Fatalf("events %v, want %v", events, want)
+	}
+	if b.Version() != start+5 {
+		t.Errorf("Version() = %d, want %d", b.Version(), start+5)
+	}
+
+	unsubscribe()
+	b.Insert(0, "x")
+	if len(events) != len(want) {
+		t.Errorf("unsubscribed listener got %v", events[len(want):])
+	}
+	if others != len(want)+1 {
+		t.Errorf("other listener got %d events, want %d", others, len(want)+1)
+	}
+}
+
+// TestChangeEventsReplay applies every event to a copy of the text, which
+// has to end up the same as the buffer.
+func TestChangeEventsReplay(t *testing.T) {
+	b := NewBuffer()
+	b.Load("one\ntwo\nthree")
+	text := b.String()
+	b.Subscribe(func(e ChangeEvent) {
+		text = text[:e.Range.Start] + e.Text + text[e.Range.End:]
+	})
+
+	b.Insert(3, " and a half")
+	b.Delete(Range{Start: 0, End: 4})
+	b.Undo()
+	b.Insert(b.Len(), "\nfour")
+	b.Undo()
+	b.Redo()
+	b.Load("new file")
+	if text != b.String() {
+		t.Errorf("replayed events give %q, want %q", text, b.String())
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+// InsertAtCursor inserts input at row and rune column col.
+func InsertAtCursor(buffer *Buffer, input string, row, col int) {
+	buffer.Insert(buffer.PosToOffset(row, col), input)
+}
+
+// InsertAtCursors inserts input at every cursor, replacing the selections,
+// as a single change. Typing joins the previous undo step the same way with
+// several cursors as with one.
+func InsertAtCursors(buffer *Buffer, cm *CursorManager, input string) {
+	if len(cm.Cursors) == 1 && !cm.HasSelection() {
+		InsertAtCursor(buffer, input, cm.Cursors[0].Row, cm.Cursors[0].Col) // Can join the previous undo step while typing
+		return
+	}
+	buffer.beginBatch()
+	defer buffer.Commit()
+	DeleteSelectedText(buffer, cm)
+	cm.ClearAllSelections()
+	for i := range cm.Cursors {
+		// The cursors behind this one move along with the inserted text
+		InsertAtCursor(buffer, input, cm.Cursors[i].Row, cm.Cursors[i].Col)
+	}
+}
+
+// DeleteAtCursors deletes the selection of every cursor, or the character
+// before cursors without one, as a single undo step. Cursors that end up in
+// the same place are merged.
+func DeleteAtCursors(buffer *Buffer, cm *CursorManager) {
+	deleteAtCursors(buffer, cm, func(c *Cursor) (int, int) {
+		if c.Col > 0 {
+			return c.Row, buffer.PrevGrapheme(c.Row, c.Col) // The whole character, even if it is made of several runes
+		}
+		if c.Row > 0 {
+			return c.Row - 1, buffer.LineLen(c.Row - 1) // Merge with previous line
+		}
+		return c.Row, c.Col
+	})
+}
+
+// DeleteWordLeft is DeleteAtCursors deleting back to the start of the word
+// before each cursor, or of the subword with subword set.
+func DeleteWordLeft(buffer *Buffer, cm *CursorManager, subword bool) {
+	deleteAtCursors(buffer, cm, func(c *Cursor) (int, int) {
+		return buffer.PrevWord(c.Row, c.Col, subword)
+	})
+}
+
+// DeleteWordRight is DeleteAtCursors deleting up to the end of the word
+// after each cursor, or of the subword with subword set.
+func DeleteWordRight(buffer *Buffer, cm *CursorManager, subword bool) {
+	deleteAtCursors(buffer, cm, func(c *Cursor) (int, int) {
+		return buffer.NextWord(c.Row, c.Col, subword)
+	})
+}
+
+// deleteAtCursors deletes the selection of every cursor, or the text between
+// the cursor and the position to returns for it.
+func deleteAtCursors(buffer *Buffer, cm *CursorManager, to func(c *Cursor) (int, int)) {
+	single := len(cm.Cursors) == 1 && !cm.HasSelection() // Can join the previous undo step on its own
+	if !single {
+		buffer.beginBatch()
+	}
+	for i := range cm.Cursors {
+		c := &cm.Cursors[i]
+		start, end := c.span()
+		if start == end {
+			row, col := to(c)
+			if end = (pos{row, col}); end.less(start) {
+				start, end = end, start
+			}
+		}
+		// The cursors follow the deleted text on their own
+		buffer.Delete(Range{Start: buffer.PosToOffset(start.row, start.col), End: buffer.PosToOffset(end.row, end.col)})
+	}
+	if !single {
+		buffer.Commit()
+	}
+	cm.ClearAllSelections()
+	cm.Merge()
+}
+
+// ClampCursor keeps the cursor inside the text after the buffer changed
+// underneath it, e.g. after an undo or redo.
+func ClampCursor(buffer *Buffer, cursor *Cursor) {
+	if cursor.Row >= buffer.LineCount() {
+		cursor.Row = buffer.LineCount() - 1
+	}
+	if cursor.Col > buffer.LineLen(cursor.Row) {
+		cursor.Col = buffer.LineLen(cursor.Row)
+	}
+	cursor.Col = buffer.SnapGrapheme(cursor.Row, cursor.Col)
+}
@@ -0,0 +1,217 @@
+package core
+
+// Selection represents a text selection region
+type Selection struct {
+	StartRow, StartCol int
+	EndRow, EndCol     int
+	Active             bool
+}
+
+// Cursor represents a single cursor position and its selection
+type Cursor struct {
+	Row, Col  int
+	X, Y      int32 // X and Y are the render positions
+	Selection Selection
+
+	goal    int // Visual column Up and Down aim for, while hasGoal is set
+	goalAt  pos // Where the last vertical move left the cursor; moving elsewhere drops the goal
+	hasGoal bool
+}
+
+// CursorManager manages the cursors of a buffer. There is always at least
+// one; the primary cursor is the one the view follows.
+type CursorManager struct {
+	Cursors       []Cursor
+	PrimaryCursor int // Index of the primary cursor
+
+	marks []cursorMarks // Where each cursor is in the buffer it tracks
+}
+
+// cursorMarks hold a cursor and its selection while the buffer is edited.
+type cursorMarks struct {
+	head, start, end *Mark
+}
+
+func NewCursorManager() *CursorManager {
+	return &CursorManager{
+		Cursors: []Cursor{
+			{Row: 0, Col: 0, Selection: Selection{}},
+		},
+		PrimaryCursor: 0,
+	}
+}
+
+// CursorState is a saved copy of all cursors and their selections.
+type CursorState struct {
+	Cursors       []Cursor
+	PrimaryCursor int
+}
+
+// Snapshot returns a copy of the current cursors.
+func (cm *CursorManager) Snapshot() CursorState {
+	return CursorState{
+		Cursors:       append([]Cursor(nil), cm.Cursors...),
+		PrimaryCursor: cm.PrimaryCursor,
+	}
+}
+
+// Restore puts back cursors saved by Snapshot. An empty state is ignored.
+func (cm *CursorManager) Restore(state CursorState) {
+	if len(state.Cursors) == 0 {
+		return
+	}
+	cm.Cursors = append(cm.Cursors[:0], state.Cursors...)
+	cm.PrimaryCursor = state.PrimaryCursor
+}
+
+// pin moves the marks of every cursor to its current position in b, making
+// marks for new cursors and removing those of cursors that are gone.
+func (cm *CursorManager) pin(b *Buffer) {
+	for len(cm.marks) > len(cm.Cursors) {
+		last := cm.marks[len(cm.marks)-1]
+		b.RemoveMark(last.head)
+		b.RemoveMark(last.start)
+		b.RemoveMark(last.end)
+		cm.marks = cm.marks[:len(cm.marks)-1]
+	}
+	for len(cm.marks) < len(cm.Cursors) {
+		cm.marks = append(cm.marks, cursorMarks{
+			head:  b.NewMark(0, StickRight),
+			start: b.NewMark(0, StickRight),
+			end:   b.NewMark(0, StickLeft),
+		})
+	}
+
+	for i, c := range cm.Cursors {
+		m := cm.marks[i]
+		m.head.SetPos(c.Row, c.Col)
+		if c.Selection.Active {
+			// Text typed at either edge of a selection stays outside of it
+			m.start.SetPos(c.Selection.StartRow, c.Selection.StartCol)
+			m.end.SetPos(c.Selection.EndRow, c.Selection.EndCol)
+			if m.start.Offset() > m.end.Offset() {
+				m.start.Stick, m.end.Stick = StickLeft, StickRight
+			} else {
+				m.start.Stick, m.end.Stick = StickRight, StickLeft
+			}
+		}
+	}
+}
+
+// follow moves every cursor to where its marks ended up after an edit. The
+// edit also ends any run of vertical moves.
+func (cm *CursorManager) follow() {
+	for i := range cm.Cursors {
+		c, m := &cm.Cursors[i], cm.marks[i]
+		c.Row, c.Col = m.head.Pos()
+		c.hasGoal = false
+		if c.Selection.Active {
+			c.Selection.StartRow, c.Selection.StartCol = m.start.Pos()
+			c.Selection.EndRow, c.Selection.EndCol = m.end.Pos()
+		}
+	}
+}
+
+func (cm *CursorManager) GetPrimary() *Cursor {
+	return &cm.Cursors[cm.PrimaryCursor]
+}
+
+func (cm *CursorManager) ClearAllSelections() {
+	for i := range cm.Cursors {
+		cm.Cursors[i].Selection.Active = false
+	}
+}
+
+func (cm *CursorManager) HasSelection() bool {
+	for _, cursor := range cm.Cursors {
+		if cursor.Selection.Active {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSelectedText returns the selected text for a given cursor
+func (cm *CursorManager) GetSelectedText(cursorIndex int, buffer *Buffer) string {
+	if cursorIndex >= len(cm.Cursors) {
+		return ""
+	}
+
+	cursor := cm.Cursors[cursorIndex]
+	if !cursor.Selection.Active {
+		return ""
+	}
+
+	return GetTextInRange(buffer, cursor.Selection.StartRow, cursor.Selection.StartCol,
+		cursor.Selection.EndRow, cursor.Selection.EndCol)
+}
+
+func (cm *CursorManager) SetRenderPos(row, col int, x, y int32) {
+	for i := range cm.Cursors {
+		if cm.Cursors[i].Row == row && cm.Cursors[i].Col == col {
+			cm.Cursors[i].X = x
+			cm.Cursors[i].Y = y
+			return
+		}
+	}
+}
+
+// DeleteSelectedText deletes the selection of every cursor as one change,
+// leaving each cursor where its selection started. The selections stay
+// active but empty; callers clear them.
+func DeleteSelectedText(buffer *Buffer, cm *CursorManager) {
+	buffer.Begin()
+	defer buffer.Commit()
+	for i := range cm.Cursors {
+		c := &cm.Cursors[i]
+		start, end := c.span()
+		if start == end {
+			continue
+		}
+		buffer.Delete(Range{Start: buffer.PosToOffset(start.row, start.col), End: buffer.PosToOffset(end.row, end.col)})
+		c.Row, c.Col = c.Selection.StartRow, c.Selection.StartCol // Both ends of the selection are there now
+	}
+}
+
+func IsCharacterSelected(row, col int, selection Selection) bool {
+	if !selection.Active {
+		return false
+	}
+
+	startRow, startCol := selection.StartRow, selection.StartCol
+	endRow, endCol := selection.EndRow, selection.EndCol
+
+	// Normalize selection (ensure start comes before end)
+	if startRow > endRow || (startRow == endRow && startCol > endCol) {
+		startRow, endRow = endRow, startRow
+		startCol, endCol = endCol, startCol
+	}
+
+	if row < startRow || row > endRow {
+		return false
+	}
+
+	if row == startRow && row == endRow {
+		return col >= startCol && col < endCol
+	} else if row == startRow {
+		return col >= startCol
+	} else if row == endRow {
+		return col < endCol
+	} else {
+		return true // Middle rows are fully selected
+	}
+}
+
+func GetTextInRange(buffer *Buffer, startRow, startCol, endRow, endCol int) string {
+	// Normalize selection
+	if startRow > endRow || (startRow == endRow && startCol > endCol) {
+		startRow, endRow = endRow, startRow
+		startCol, endCol = endCol, startCol
+	}
+
+	if startRow < 0 || endRow < 0 {
+		return ""
+	}
+
+	return buffer.Slice(Range{Start: buffer.PosToOffset(startRow, startCol), End: buffer.PosToOffset(endRow, endCol)})
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Charset is the character set a file is stored in.
+type Charset int
+
+const (
+	CharsetUTF8 Charset = iota
+	CharsetUTF16LE
+	CharsetUTF16BE
+	CharsetLatin1 // ISO-8859-1, every byte is one character
+)
+
+// Encoding describes how the text of a file is stored on disk. Text is always
+// edited as UTF-8 and converted back when saving.
+type Encoding struct {
+	Charset Charset
+	BOM     bool // the file starts with a byte order mark
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+const encodingSniffLen = 4096 // Bytes looked at when guessing UTF-16 without a BOM
+
+func (e Encoding) String() string {
+	var name string
+	switch e.Charset {
+	case CharsetUTF16LE:
+		name = "UTF-16 LE"
+	case CharsetUTF16BE:
+		name = "UTF-16 BE"
+	case CharsetLatin1:
+		name = "Latin-1"
+	default:
+		name = "UTF-8"
+	}
+	if e.BOM {
+		name += " BOM"
+	}
+	return name
+}
+
+// ParseCharset parses a character set name as given on the command line:
+// "utf-8", "utf-16le", "utf-16be" or "latin-1".
+func ParseCharset(name string) (Charset, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "_", "-")) {
+	case "utf-8", "utf8":
+		return CharsetUTF8, nil
+	case "utf-16le", "utf-16-le", "utf16le":
+		return CharsetUTF16LE, nil
+	case "utf-16be", "utf-16-be", "utf16be":
+		return CharsetUTF16BE, nil
+	case "latin-1", "latin1", "iso-8859-1":
+		return CharsetLatin1, nil
+	}
+	return 0, fmt.Errorf("unknown encoding %q", name)
+}
+
+// hasBOM reports whether data starts with the byte order mark of charset.
+func hasBOM(data []byte, charset Charset) bool {
+	switch charset {
+	case CharsetUTF8:
+		return bytes.HasPrefix(data, bomUTF8)
+	case CharsetUTF16LE:
+		return bytes.HasPrefix(data, bomUTF16LE)
+	case CharsetUTF16BE:
+		return bytes.HasPrefix(data, bomUTF16BE)
+	}
+	return false
+}
+
+// DetectEncoding guesses the encoding of data from its byte order mark, or
+// failing that from the pattern of zero bytes typical of UTF-16 text. Data
+// that is not valid UTF-8 but contains no UTF-8 multi-byte sequences at all
+// is taken to be Latin-1.
+func DetectEncoding(data []byte) Encoding {
+	for _, charset := range []Charset{CharsetUTF8, CharsetUTF16LE, CharsetUTF16BE} {
+		if hasBOM(data, charset) {
+			return Encoding{Charset: charset, BOM: true}
+		}
+	}
+
+	if charset, ok := sniffUTF16(data); ok {
+		return Encoding{Charset: charset}
+	}
+	if utf8.Valid(data) || hasUTF8Sequence(data) {
+		return Encoding{Charset: CharsetUTF8}
+	}
+	return Encoding{Charset: CharsetLatin1}
+}
+
+// sniffUTF16 recognizes mostly-ASCII UTF-16 text without a BOM, where every
+// other byte is zero.
+func sniffUTF16(data []byte) (Charset, bool) {
+	sample := data[:min(len(data), encodingSniffLen)]
+	pairs := len(sample) / 2
+	if pairs == 0 || len(data)%2 != 0 {
+		return 0, false
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 {
+			evenZeros++
+		}
+		if sample[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros*10 > pairs*4 && evenZeros*10 < pairs:
+		return CharsetUTF16LE, true
+	case evenZeros*10 > pairs*4 && oddZeros*10 < pairs:
+		return CharsetUTF16BE, true
+	}
+	return 0, false
+}
+
+// hasUTF8Sequence reports whether data contains at least one valid UTF-8
+// multi-byte character.
+func hasUTF8Sequence(data []byte) bool {
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r != utf8.RuneError && size > 1 {
+			return true
+		}
+		data = data[size:]
+	}
+	return false
+}
+
+// Decode converts data stored in enc into UTF-8 text for editing. UTF-8 data
+// is used as is, so bytes that are not valid UTF-8 are kept and written back
+// unchanged by Encode.
+func Decode(data []byte, enc Encoding) (string, error) {
+	switch enc.Charset {
+	case CharsetUTF16LE, CharsetUTF16BE:
+		if enc.BOM {
+			data = data[min(len(data), 2):]
+		}
+		if len(data)%2 != 0 {
+			return "", errors.New("odd number of bytes in UTF-16 data")
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if enc.Charset == CharsetUTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units)), nil
+	case CharsetLatin1:
+		runes := make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		return string(runes), nil
+	default:
+		if enc.BOM {
+			data = bytes.TrimPrefix(data, bomUTF8)
+		}
+		return string(data), nil
+	}
+}
+
+// Encode converts edited text back into enc for saving.
+func Encode(text string, enc Encoding) ([]byte, error) {
+	switch enc.Charset {
+	case CharsetUTF16LE, CharsetUTF16BE:
+		units := utf16.Encode([]rune(text))
+		if enc.BOM {
+			units = append([]uint16{0xFEFF}, units...)
+		}
+		out := make([]byte, 0, 2*len(units))
+		for _, u := range units {
+			if enc.Charset == CharsetUTF16LE {
+				out = append(out, byte(u), byte(u>>8))
+			} else {
+				out = append(out, byte(u>>8), byte(u))
+			}
+		}
+		return out, nil
+	case CharsetLatin1:
+		out := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xFF {
+				return nil, fmt.Errorf("character %q cannot be saved as %s", r, enc)
+			}
+			out = append(out, byte(r))
+		}
+		return out, nil
+	default:
+		if enc.BOM {
+			return append(append([]byte(nil), bomUTF8...), text...), nil
+		}
+		return []byte(text), nil
+	}
+}
+
+// DecodeFile decodes the contents of a file, detecting the encoding unless
+// a charset override is given. If the data can't be decoded and encoded back
+// to the exact same bytes, it falls back to plain UTF-8, which always
+// round-trips.
+func DecodeFile(data []byte, override *Charset) (string, Encoding) {
+	enc := DetectEncoding(data)
+	if override != nil {
+		enc = Encoding{Charset: *override, BOM: hasBOM(data, *override)}
+	}
+	text, err := Decode(data, enc)
+	if err == nil {
+		if encoded, err := Encode(text, enc); err == nil && bytes.Equal(encoded, data) {
+			return text, enc
+		}
+	}
+	return string(data), Encoding{Charset: CharsetUTF8}
+}
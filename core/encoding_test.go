@@ -0,0 +1,70 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Encoding
+		text string
+	}{
+		{"utf8", []byte("héllo\n"), Encoding{Charset: CharsetUTF8}, "héllo\n"},
+		{"utf8 bom", append([]byte{0xEF, 0xBB, 0xBF}, "x\r\ny"...), Encoding{Charset: CharsetUTF8, BOM: true}, "x\r\ny"},
+		{"invalid utf8", []byte("ab\xff\xfe\x80c€"), Encoding{Charset: CharsetUTF8}, "ab\xff\xfe\x80c€"},
+		{"latin1", []byte("caf\xe9 na\xefve\n"), Encoding{Charset: CharsetLatin1}, "café naïve\n"},
+		{"utf16le bom", []byte{0xFF, 0xFE, 'h', 0, 'i', 0, '\r', 0, '\n', 0}, Encoding{Charset: CharsetUTF16LE, BOM: true}, "hi\r\n"},
+		{"utf16be bom", []byte{0xFE, 0xFF, 0, 'h', 0xD8, 0x3D, 0xDE, 0x00}, Encoding{Charset: CharsetUTF16BE, BOM: true}, "h😀"},
+		{"utf16be", []byte{0, 'h', 0, 'i', 0, '\n'}, Encoding{Charset: CharsetUTF16BE}, "hi\n"},
+		{"empty", nil, Encoding{Charset: CharsetUTF8}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, enc := DecodeFile(tt.data, nil)
+			if enc != tt.want {
+				t.Errorf("encoding %v, want %v", enc, tt.want)
+			}
+			if text != tt.text {
+				t.Errorf("text %q, want %q", text, tt.text)
+			}
+
+			b := NewBuffer()
+			b.Load(text)
+			b.Encoding = enc
+			out, err := Encode(b.TextWithLineEndings(), b.Encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, tt.data) {
+				t.Errorf("saved %q, want %q", out, tt.data)
+			}
+		})
+	}
+}
+
+func TestDecodeFileOverride(t *testing.T) {
+	tests := []struct {
+		charset Charset
+		data    []byte
+		want    string
+	}{
+		{CharsetUTF16LE, []byte{'h', 0}, "h"},
+		{CharsetLatin1, []byte("h\xe9"), "hé"},
+		{CharsetUTF8, []byte("hé"), "hé"},
+	}
+	for _, tt := range tests {
+		text, enc := DecodeFile(tt.data, &tt.charset)
+		if enc.Charset != tt.charset || enc.BOM || text != tt.want {
+			t.Errorf("%v: %q as %v, want %q", tt.charset, text, enc, tt.want)
+		}
+	}
+}
+
+func TestEncodeUnrepresentable(t *testing.T) {
+	if _, err := Encode("€", Encoding{Charset: CharsetLatin1}); err == nil {
+		t.Error("encoding € as Latin-1 didn't fail")
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// GraphemeClusters splits line into user-perceived characters (extended
+// grapheme clusters, UAX #29), such as a letter with its combining accents
+// or a whole ZWJ emoji sequence.
+func GraphemeClusters(line string) []string {
+	var clusters []string
+	state := -1
+	for line != "" {
+		var cluster string
+		cluster, line, _, state = uniseg.FirstGraphemeClusterInString(line, state)
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// graphemeCols returns the column, in runes, at which each grapheme cluster
+// of line starts, followed by the length of the line. These are the only
+// columns a cursor may be at.
+func graphemeCols(line string) []int {
+	cols := []int{0}
+	col := 0
+	for _, cluster := range GraphemeClusters(line) {
+		col += utf8.RuneCountInString(cluster)
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// PrevGrapheme returns the column of the grapheme cluster boundary before
+// col on line row, or 0 at the start of the line.
+func (b *Buffer) PrevGrapheme(row, col int) int {
+	prev := 0
+	for _, c := range graphemeCols(b.Line(row)) {
+		if c >= col {
+			break
+		}
+		prev = c
+	}
+	return prev
+}
+
+// NextGrapheme returns the column of the grapheme cluster boundary after col
+// on line row, or the line length at the end of the line.
+func (b *Buffer) NextGrapheme(row, col int) int {
+	cols := graphemeCols(b.Line(row))
+	for _, c := range cols {
+		if c > col {
+			return c
+		}
+	}
+	return cols[len(cols)-1]
+}
+
+// SnapGrapheme moves col back to the start of the grapheme cluster it falls
+// inside, so the cursor never splits a character.
+func (b *Buffer) SnapGrapheme(row, col int) int {
+	if col <= 0 {
+		return 0
+	}
+	return b.PrevGrapheme(row, col+1)
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+// a, e with a combining accent (2 runes), a flag (2) and a ZWJ family (5)
+const clusterLine = "aé🇩🇪👨‍👩‍👧x"
+
+func TestGraphemeCols(t *testing.T) {
+	want := []int{0, 1, 3, 5, 10, 11}
+	if got := graphemeCols(clusterLine); !slices.Equal(got, want) {
+		t.Errorf("graphemeCols = %v, want %v", got, want)
+	}
+	if got := graphemeCols(""); !slices.Equal(got, []int{0}) {
+		t.Errorf("graphemeCols of an empty line = %v, want [0]", got)
+	}
+}
+
+func TestGraphemeMoves(t *testing.T) {
+	b := NewBuffer()
+	b.Load(clusterLine)
+	tests := []struct {
+		col, prev, next, snap int
+	}{
+		{0, 0, 1, 0},
+		{1, 0, 3, 1},
+		{2, 1, 3, 1}, // Between e and its accent
+		{4, 3, 5, 3},
+		{7, 5, 10, 5},
+		{10, 5, 11, 10},
+		{11, 10, 11, 11},
+	}
+	for _, tt := range tests {
+		if got := b.PrevGrapheme(0, tt.col); got != tt.prev {
+			t.Errorf("PrevGrapheme(0, %d) = %d, want %d", tt.col, got, tt.prev)
+		}
+		if got := b.NextGrapheme(0, tt.col); got != tt.next {
+			t.Errorf("NextGrapheme(0, %d) = %d, want %d", tt.col, got, tt.next)
+		}
+		if got := b.SnapGrapheme(0, tt.col); got != tt.snap {
+			t.Errorf("SnapGrapheme(0, %d) = %d, want %d", tt.col, got, tt.snap)
+		}
+	}
+}
+
+func TestDeleteGrapheme(t *testing.T) {
+	b := NewBuffer()
+	b.Load(clusterLine)
+	cm := &CursorManager{Cursors: []Cursor{{Row: 0, Col: 10}}}
+	b.TrackCursors(cm)
+	want := []string{
+		"aé🇩🇪x",
+		"aéx",
+		"ax",
+		"x",
+	}
+	for _, text := range want {
+		DeleteAtCursors(b, cm)
+		if b.String() != text {
+			t.Fatalf("backspace left %q, want %q", b.String(), text)
+		}
+	}
+	if cm.Cursors[0].Col != 0 {
+		t.Errorf("cursor at column %d, want 0", cm.Cursors[0].Col)
+	}
+}
+
+func TestClampCursorSnapsToGrapheme(t *testing.T) {
+	b := NewBuffer()
+	b.Load(clusterLine)
+	c := Cursor{Row: 0, Col: 8} // Inside the family
+	ClampCursor(b, &c)
+	if c.Col != 5 {
+		t.Errorf("clamped to column %d, want 5", c.Col)
+	}
+}
@@ -0,0 +1,389 @@
+package core
+
+import (
+	"slices"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	undoMemoryLimit     = 64 << 20    // Maximum bytes of edit text kept in the undo history
+	editOverhead        = 64          // Rough per-edit bookkeeping cost counted against the limit
+	undoCoalesceTimeout = time.Second // A pause longer than this starts a new undo step
+)
+
+// Edit is a single change to the buffer: Deleted was removed at Pos and
+// Inserted was put in its place.
+type Edit struct {
+	Pos      int
+	Deleted  string
+	Inserted string
+
+	// Whether each line break in Deleted and in Inserted ends in \r\n in the
+	// file, so undoing a deletion brings back the endings it removed. New
+	// breaks get the default ending when the edit is first applied. An edit
+	// without text converts line endings instead, see SetLineEnding: these
+	// then hold the ending of every line followed by the default ending.
+	DeletedCRLF  []bool
+	InsertedCRLF []bool
+
+	// Where Deleted and Inserted are in the piece table once the edit was
+	// applied, so undo and redo put the same bytes back instead of adding
+	// them to the table again.
+	deletedSpans, insertedSpans []piece
+}
+
+// size approximates the memory held by the edit.
+func (e Edit) size() int {
+	return len(e.Deleted) + len(e.Inserted) + len(e.DeletedCRLF) + len(e.InsertedCRLF) + editOverhead
+}
+
+type editKind int
+
+const (
+	editInsert  editKind = iota // only inserts text
+	editDelete                  // only deletes text
+	editReplace                 // both, e.g. SetContent
+	editEndings                 // neither, only converts line endings
+)
+
+func (e Edit) kind() editKind {
+	switch {
+	case e.Deleted == "" && e.Inserted == "":
+		return editEndings
+	case e.Deleted == "":
+		return editInsert
+	case e.Inserted == "":
+		return editDelete
+	default:
+		return editReplace
+	}
+}
+
+// invert returns the edit that undoes e.
+func (e Edit) invert() Edit {
+	return Edit{
+		Pos:          e.Pos,
+		Deleted:      e.Inserted,
+		Inserted:     e.Deleted,
+		DeletedCRLF:  e.InsertedCRLF,
+		InsertedCRLF: e.DeletedCRLF,
+
+		deletedSpans:  e.insertedSpans,
+		insertedSpans: e.deletedSpans,
+	}
+}
+
+// UndoEntry is a single undo step: a group of edits that are undone and
+// redone together.
+type UndoEntry struct {
+	Edits []Edit
+
+	// Cursor positions and selections right before and right after the
+	// edits, restored by Undo and Redo respectively.
+	CursorsBefore CursorState
+	CursorsAfter  CursorState
+
+	kind   editKind // kind of the last edit, used for coalescing
+	batch  int      // edits of the last keystroke if it edited at several cursors, else 0
+	sealed bool     // no more edits may be merged into this entry
+}
+
+func (e *UndoEntry) size() int {
+	size := 0
+	for _, edit := range e.Edits {
+		size += edit.size()
+	}
+	return size
+}
+
+// canCoalesce reports whether edit, made at now, continues the run of typing
+// or deleting recorded in node. A run ends after a pause, when the kind of
+// edit changes, when the edit is not next to the previous one, or where a
+// word follows whitespace in the text.
+func canCoalesce(node *UndoNode, edit Edit, now time.Time) bool {
+	entry := &node.Entry
+	if edit.kind() == editReplace || edit.kind() != entry.kind || now.Sub(node.Time) > undoCoalesceTimeout {
+		return false
+	}
+
+	return continues(entry.Edits[len(entry.Edits)-1], edit)
+}
+
+// canCoalesceBatch is canCoalesce for the edits of one keystroke at several
+// cursors, in text order: each has to continue the edit of the same cursor
+// in the keystroke recorded last in node.
+func canCoalesceBatch(node *UndoNode, edits []Edit, now time.Time) bool {
+	entry := &node.Entry
+	if entry.batch != len(edits) || now.Sub(node.Time) > undoCoalesceTimeout {
+		return false
+	}
+	last := entry.Edits[len(entry.Edits)-len(edits):]
+	shift := 0 // How far the edits before this one moved the text
+	for i, edit := range edits {
+		if edit.kind() == editReplace || edit.kind() != entry.kind {
+			return false
+		}
+		before := edit
+		before.Pos -= shift // Where the edit is in the text as the last keystroke left it
+		if !continues(last[i], before) {
+			return false
+		}
+		shift += len(edit.Inserted) - len(edit.Deleted)
+	}
+	return true
+}
+
+// continues reports whether edit carries on the typing or deleting of last
+// right next to it, without starting a new word.
+func continues(last, edit Edit) bool {
+	switch edit.kind() {
+	case editInsert:
+		return edit.Pos == last.Pos+len(last.Inserted) && !startsWord(last.Inserted, edit.Inserted)
+	case editDelete:
+		// Backspace removes text before the previous deletion, Delete at the same position.
+		if edit.Pos+len(edit.Deleted) == last.Pos {
+			return !startsWord(edit.Deleted, last.Deleted)
+		}
+		return edit.Pos == last.Pos && !startsWord(last.Deleted, edit.Deleted)
+	}
+	return false
+}
+
+// startsWord reports whether next begins a new word after prev, which ends
+// in whitespace. prev and next are in text order.
+func startsWord(prev, next string) bool {
+	p, _ := utf8.DecodeLastRuneInString(prev)
+	n, _ := utf8.DecodeRuneInString(next)
+	return unicode.IsSpace(p) && !unicode.IsSpace(n)
+}
+
+// UndoNode is one state of the text in the undo tree. Every node except the
+// root holds the entry that leads to it from its parent.
+type UndoNode struct {
+	Entry    UndoEntry
+	Parent   *UndoNode
+	Children []*UndoNode
+	Seq      int       // Order in which the states were created, the root is 0
+	Time     time.Time // When the state was last changed
+
+	redo    int  // Index of the child Redo moves to
+	dropped bool // Removed from the tree to stay under the memory limit
+}
+
+// UndoTree keeps every state the text has been in, so undoing and then
+// editing starts a new branch instead of throwing the undone edits away.
+// Once the edits it holds go over undoMemoryLimit, the oldest states are
+// merged into the root.
+type UndoTree struct {
+	Root    *UndoNode
+	Current *UndoNode
+
+	nodes   []*UndoNode // Every node, oldest first
+	nextSeq int
+	size    int
+}
+
+func NewUndoTree() *UndoTree {
+	root := &UndoNode{Time: time.Now()}
+	return &UndoTree{
+		Root:    root,
+		Current: root,
+		nodes:   []*UndoNode{root},
+		nextSeq: 1,
+	}
+}
+
+// Nodes returns every state in the tree, oldest first.
+func (t *UndoTree) Nodes() []*UndoNode {
+	return t.nodes
+}
+
+// Len returns the number of states in the tree, including the root.
+func (t *UndoTree) Len() int {
+	return len(t.nodes)
+}
+
+// open returns the current node if more edits may still be merged into it.
+func (t *UndoTree) open() *UndoNode {
+	if t.Current == t.Root || t.Current.Entry.sealed || len(t.Current.Children) > 0 {
+		return nil
+	}
+	return t.Current
+}
+
+// push adds entry as a new child of the current state and moves to it.
+func (t *UndoTree) push(entry UndoEntry, now time.Time) {
+	node := &UndoNode{Entry: entry, Parent: t.Current, Seq: t.nextSeq, Time: now}
+	t.nextSeq++
+	t.Current.Children = append(t.Current.Children, node)
+	t.Current.redo = len(t.Current.Children) - 1
+	t.Current = node
+	t.nodes = append(t.nodes, node)
+	t.size += entry.size()
+	t.trim()
+}
+
+// extend merges edit into the current node.
+func (t *UndoTree) extend(edit Edit, now time.Time) {
+	node := t.Current
+	node.Entry.Edits = append(node.Entry.Edits, edit)
+	node.Entry.kind = edit.kind()
+	node.Entry.batch = 0
+	node.Time = now
+	t.size += edit.size()
+	t.trim()
+}
+
+// joinBatch ends a keystroke that edited at several cursors and created the
+// current state right after prev. If prev was still open and every cursor's
+// edit continues the typing or deleting of the same cursor there, the new
+// state is merged into prev, the way a single cursor's keystrokes are.
+func (t *UndoTree) joinBatch(prev *UndoNode, open bool) {
+	node := t.Current
+	if node == prev || node.Parent != prev {
+		return // Nothing was edited
+	}
+
+	// The keystroke's own edits of the same kind, leaving out the selections
+	// it replaced, are what the next keystroke is compared with
+	edits := node.Entry.Edits
+	batch := 1
+	for batch < len(edits) && edits[len(edits)-1-batch].kind() == node.Entry.kind {
+		batch++
+	}
+
+	if open && canCoalesceBatch(prev, edits, node.Time) {
+		prev.Entry.Edits = append(prev.Entry.Edits, edits...)
+		prev.Entry.kind = node.Entry.kind
+		prev.Entry.sealed = false
+		prev.Time = node.Time
+		t.popNewest()
+		node = prev
+	}
+	node.Entry.batch = batch
+}
+
+// trim merges the oldest states into the root while the tree is over its
+// memory limit. Branches that split off before the new root are dropped.
+// The current state is never merged away.
+func (t *UndoTree) trim() {
+	if t.size <= undoMemoryLimit {
+		return
+	}
+
+	for t.size > undoMemoryLimit && t.Root != t.Current {
+		next := t.Current
+		for next.Parent != t.Root {
+			next = next.Parent
+		}
+		for _, child := range t.Root.Children {
+			if child != next {
+				t.drop(child)
+			}
+		}
+
+		t.Root.dropped = true
+		t.size -= next.Entry.size()
+		next.Entry = UndoEntry{} // Let the merged text be collected
+		next.Parent = nil
+		t.Root = next
+	}
+
+	nodes := t.nodes[:0]
+	for _, node := range t.nodes {
+		if !node.dropped {
+			nodes = append(nodes, node)
+		}
+	}
+	clear(t.nodes[len(nodes):])
+	t.nodes = nodes
+}
+
+// truncate keeps only the first n edits of the state created right after
+// start, removing the state when n is 0. This is how a rolled back
+// transaction leaves the history.
+func (t *UndoTree) truncate(start *UndoNode, n int) {
+	node := t.Current
+	if node == start || node.Parent != start {
+		return
+	}
+	for _, edit := range node.Entry.Edits[n:] {
+		t.size -= edit.size()
+	}
+	node.Entry.Edits = node.Entry.Edits[:n]
+	if n > 0 {
+		return
+	}
+	t.popNewest()
+}
+
+// popNewest removes the current state, which has to be the newest one, and
+// moves back to its parent.
+func (t *UndoTree) popNewest() {
+	node := t.Current
+	// The state is the newest one, so it is last everywhere
+	node.Parent.Children = node.Parent.Children[:len(node.Parent.Children)-1]
+	t.nodes[len(t.nodes)-1] = nil
+	t.nodes = t.nodes[:len(t.nodes)-1]
+	t.Current = node.Parent
+}
+
+func (t *UndoTree) drop(node *UndoNode) {
+	node.dropped = true
+	t.size -= node.Entry.size()
+	for _, child := range node.Children {
+		t.drop(child)
+	}
+}
+
+// IndexOf returns the position of node in t.nodes.
+func (t *UndoTree) IndexOf(node *UndoNode) int {
+	for i, n := range t.nodes {
+		if n == node {
+			return i
+		}
+	}
+	return -1
+}
+
+// path returns the way from state from to state to: up lists the states
+// undone on the way to their closest common ancestor, starting with from,
+// and down the states redone from there, ending with to.
+func (t *UndoTree) path(from, to *UndoNode) (up, down []*UndoNode) {
+	ancestors := make(map[*UndoNode]bool)
+	for n := to; n != nil; n = n.Parent {
+		ancestors[n] = true
+	}
+	n := from
+	for ; !ancestors[n]; n = n.Parent {
+		up = append(up, n)
+	}
+	for m := to; m != n; m = m.Parent {
+		down = append(down, m)
+	}
+	slices.Reverse(down)
+	return up, down
+}
+
+// childIndex returns the position of node among its parent's children.
+func childIndex(node *UndoNode) int {
+	for i, child := range node.Parent.Children {
+		if child == node {
+			return i
+		}
+	}
+	return -1
+}
+
+// latestBefore returns the newest state changed at or before when. States
+// older than the root resolve to the root.
+func (t *UndoTree) latestBefore(when time.Time) *UndoNode {
+	for i := len(t.nodes) - 1; i > 0; i-- {
+		if !t.nodes[i].Time.After(when) {
+			return t.nodes[i]
+		}
+	}
+	return t.Root
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUndoRedo(t *testing.T) {
+	b := NewBuffer()
+	b.Load("hello world")
+	steps := []struct {
+		edit func()
+		want string
+	}{
+		{func() { b.Insert(5, ",") }, "hello, world"},
+		{func() { b.Delete(Range{Start: 0, End: 1}) }, "ello, world"},
+		{func() { b.Insert(0, "H") }, "Hello, world"},
+		{func() { b.Delete(Range{Start: 5, End: 12}) }, "Hello"},
+	}
+	for _, step := range steps {
+		step.edit()
+		b.SealUndo()
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		if got := b.String(); got != steps[i].want {
+			t.Fatalf("undo to step %d: %q, want %q", i, got, steps[i].want)
+		}
+		if !b.Undo() {
+			t.Fatalf("undo of step %d failed", i)
+		}
+	}
+	if b.String() != "hello world" || b.Undo() {
+		t.Fatalf("undo past the start: %q", b.String())
+	}
+	for i := range steps {
+		if !b.Redo() {
+			t.Fatalf("redo of step %d failed", i)
+		}
+		if got := b.String(); got != steps[i].want {
+			t.Fatalf("redo step %d: %q, want %q", i, got, steps[i].want)
+		}
+	}
+	if b.Redo() {
+		t.Fatal("redo past the end")
+	}
+}
+
+func TestUndoCoalescing(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edit    func(b *Buffer)
+		steps   int    // Undo steps the edits make
+		undone  string // The text after undoing the last step
+	}{
+		{"typing a word", "", func(b *Buffer) { typeAt(b, 0, "hello") }, 1, ""},
+		{"typing words", "", func(b *Buffer) { typeAt(b, 0, "foo bar baz") }, 3, "foo bar "},
+		{"typing after a move", "ab", func(b *Buffer) { typeAt(b, 1, "x"); typeAt(b, 0, "y") }, 2, "axb"},
+		{"backspace", "foo bar", func(b *Buffer) {
+			for i := 7; i > 4; i-- {
+				b.Delete(Range{Start: i - 1, End: i})
+			}
+		}, 1, "foo bar"},
+		{"backspace over a word", "foo bar", func(b *Buffer) {
+			for i := 7; i > 2; i-- {
+				b.Delete(Range{Start: i - 1, End: i})
+			}
+		}, 2, "foo "},
+		{"forward delete", "foo bar", func(b *Buffer) {
+			for i := 0; i < 3; i++ {
+				b.Delete(Range{Start: 0, End: 1})
+			}
+		}, 1, "foo bar"},
+		{"typing then deleting", "", func(b *Buffer) {
+			typeAt(b, 0, "abc")
+			b.Delete(Range{Start: 2, End: 3})
+		}, 2, "abc"},
+		{"sealed", "", func(b *Buffer) {
+			typeAt(b, 0, "a")
+			b.SealUndo()
+			typeAt(b, 1, "b")
+		}, 2, "a"},
+		{"group", "foo bar", func(b *Buffer) {
+			b.beginUndoGroup()
+			b.Delete(Range{Start: 0, End: 3})
+			b.Insert(0, "x")
+			b.endUndoGroup()
+		}, 1, "foo bar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load(tt.content)
+			nodes := b.History.Len()
+			tt.edit(b)
+			if steps := b.History.Len() - nodes; steps != tt.steps {
+				t.Errorf("made %d undo steps, want %d", steps, tt.steps)
+			}
+			b.Undo()
+			if got := b.String(); got != tt.undone {
+				t.Errorf("after undo: %q, want %q", got, tt.undone)
+			}
+		})
+	}
+}
+
+// typeAt inserts text at offset one character at a time, as if it was typed.
+func typeAt(b *Buffer, offset int, text string) {
+	for _, r := range text {
+		b.Insert(offset, string(r))
+		offset += len(string(r))
+	}
+}
+
+func TestUndoRestoresCursors(t *testing.T) {
+	cm := NewCursorManager()
+	b := NewBuffer()
+	b.TrackCursors(cm)
+	b.Load("abc def")
+	c := cm.GetPrimary()
+	c.Col = 7
+	InsertAtCursor(b, "x", 0, 7)
+	b.SealUndo()
+	c.Col = 0 // Moved away after typing
+	b.Undo()
+	if c.Col != 7 {
+		t.Errorf("undo left the cursor at %d, want 7", c.Col)
+	}
+	c.Col = 2
+	b.Redo()
+	if c.Col != 8 {
+		t.Errorf("redo left the cursor at %d, want 8", c.Col)
+	}
+}
+
+func TestUndoTreeBranches(t *testing.T) {
+	b := NewBuffer()
+	b.Insert(0, "a")
+	b.SealUndo()
+	b.Insert(1, "b")
+	b.SealUndo()
+	b.Undo()
+	b.Insert(1, "c") // Starts a second branch after "a"
+	b.SealUndo()
+
+	steps := []struct {
+		name string
+		move func() bool
+		want string
+	}{
+		{"switch branch", func() bool { return b.SwitchBranch(1) }, "ab"},
+		{"step forward", b.StepForward, "ac"},
+		{"step back", b.StepBack, "ab"},
+		{"step back to the fork", b.StepBack, "a"},
+		{"redo the last branch used", b.Redo, "ab"},
+		{"earlier", func() bool { return b.Earlier(time.Hour) }, ""},
+		{"later", func() bool { return b.Later(time.Hour) }, "ac"},
+	}
+	for _, step := range steps {
+		if !step.move() {
+			t.Fatalf("%s: didn't move", step.name)
+		}
+		if got := b.String(); got != step.want {
+			t.Fatalf("%s: %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestUndoRedoDoesNotGrowText(t *testing.T) {
+	b := NewBuffer()
+	b.Load(strings.Repeat("some text\n", 100000))
+	b.Delete(Range{Start: 0, End: b.Len()})
+	b.SealUndo()
+	b.Insert(0, "typed over it")
+	b.SealUndo()
+	added := len(b.table.add)
+	for i := 0; i < 50; i++ {
+		b.Undo()
+		b.Undo()
+		b.Redo()
+		b.Redo()
+	}
+	if b.String() != "typed over it" {
+		t.Fatalf("text %q", b.String())
+	}
+	if len(b.table.add) != added {
+		t.Errorf("undo and redo grew the piece table from %d to %d bytes", added, len(b.table.add))
+	}
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	LargeFileThreshold = 64 << 20 // Files bigger than this open read-only in large-file mode
+	largeFileChunkSize = 4 << 20  // Bytes read at a time while indexing
+	largeFileMaxLine   = 64 << 10 // Longer lines are cut off when shown
+)
+
+// LargeFile is a read-only view of a file too big to load into a Buffer.
+// Only the line starts are kept in memory; they are indexed in the
+// background while the first lines can already be shown, and the text of a
+// line is read from disk when it is needed.
+type LargeFile struct {
+	Path     string
+	Size     int64
+	Encoding Encoding
+
+	file    *os.File
+	newline []byte // How \n is stored in the file's encoding
+	unit    int    // Bytes per code unit, so newlines are only found on unit boundaries
+
+	mu      sync.Mutex
+	starts  []int64 // Byte offset of every line found so far
+	indexed atomic.Int64
+	err     error
+	done    chan struct{}
+	stop    chan struct{}
+}
+
+// OpenLargeFile opens path and starts indexing its lines in the background.
+// The encoding is detected from the first chunk unless a charset override
+// is given.
+func OpenLargeFile(path string, override *Charset) (*LargeFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	head := make([]byte, min(info.Size(), encodingSniffLen))
+	if _, err := io.ReadFull(file, head); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	enc := DetectEncoding(head)
+	if override != nil {
+		enc = Encoding{Charset: *override, BOM: hasBOM(head, *override)}
+	}
+
+	lf := &LargeFile{
+		Path:     path,
+		Size:     info.Size(),
+		Encoding: enc,
+		file:     file,
+		newline:  []byte{'\n'},
+		unit:     1,
+		starts:   []int64{0},
+		done:     make(chan struct{}),
+		stop:     make(chan struct{}),
+	}
+	switch enc.Charset {
+	case CharsetUTF16LE:
+		lf.newline, lf.unit = []byte{'\n', 0}, 2
+	case CharsetUTF16BE:
+		lf.newline, lf.unit = []byte{0, '\n'}, 2
+	}
+	if enc.BOM {
+		bom, _ := Encode("", enc)
+		lf.starts[0] = int64(len(bom))
+	}
+
+	go lf.index()
+	return lf, nil
+}
+
+// index reads the file chunk by chunk and records where each line starts.
+func (lf *LargeFile) index() {
+	defer close(lf.done)
+
+	chunk := make([]byte, largeFileChunkSize) // A multiple of every unit size, so units never straddle chunks
+	var offset int64
+	for offset < lf.Size {
+		select {
+		case <-lf.stop:
+			return
+		default:
+		}
+
+		n, err := lf.file.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			lf.mu.Lock()
+			lf.err = err
+			lf.mu.Unlock()
+			return
+		}
+		if n == 0 {
+			break
+		}
+
+		var found []int64
+		data := chunk[:n]
+		for i := 0; ; {
+			k := bytes.Index(data[i:], lf.newline)
+			if k < 0 {
+				break
+			}
+			pos := i + k
+			if pos%lf.unit != 0 {
+				i = pos + 1
+				continue
+			}
+			found = append(found, offset+int64(pos+len(lf.newline)))
+			i = pos + len(lf.newline)
+		}
+
+		lf.mu.Lock()
+		lf.starts = append(lf.starts, found...)
+		lf.mu.Unlock()
+		offset += int64(n)
+		lf.indexed.Store(offset)
+	}
+}
+
+// Indexing reports whether lines are still being indexed.
+func (lf *LargeFile) Indexing() bool {
+	select {
+	case <-lf.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Progress returns the fraction of the file indexed so far, from 0 to 1.
+func (lf *LargeFile) Progress() float64 {
+	if lf.Size == 0 {
+		return 1
+	}
+	return float64(lf.indexed.Load()) / float64(lf.Size)
+}
+
+// Err returns the error that stopped indexing, if any.
+func (lf *LargeFile) Err() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return lf.err
+}
+
+// LineCount returns the number of lines indexed so far.
+func (lf *LargeFile) LineCount() int {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return len(lf.starts)
+}
+
+// Line returns the text of line row without its line ending. Lines longer
+// than largeFileMaxLine are cut off.
+func (lf *LargeFile) Line(row int) string {
+	lf.mu.Lock()
+	if row < 0 || row >= len(lf.starts) {
+		lf.mu.Unlock()
+		return ""
+	}
+	start := lf.starts[row]
+	end := lf.indexed.Load()
+	if row+1 < len(lf.starts) {
+		end = lf.starts[row+1] - int64(len(lf.newline))
+	}
+	lf.mu.Unlock()
+
+	truncated := false
+	if end-start > largeFileMaxLine {
+		end = start + largeFileMaxLine
+		truncated = true
+	}
+	data := make([]byte, max(end-start, 0))
+	n, _ := lf.file.ReadAt(data, start)
+	data = data[:n-n%lf.unit]
+
+	text, err := Decode(data, Encoding{Charset: lf.Encoding.Charset})
+	if err != nil {
+		return ""
+	}
+	text = strings.TrimSuffix(text, "\r")
+	if truncated {
+		text += "…"
+	}
+	return text
+}
+
+// Close stops indexing and closes the file.
+func (lf *LargeFile) Close() error {
+	close(lf.stop)
+	<-lf.done
+	return lf.file.Close()
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// openIndexed opens content as a large file and waits for the indexing.
+func openIndexed(t *testing.T, content []byte) *LargeFile {
+	t.Helper()
+	lf, err := OpenLargeFile(writeFiles(t, string(content))[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lf.Close() })
+	<-lf.done
+	if err := lf.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lf
+}
+
+func TestLargeFileLines(t *testing.T) {
+	// More than one chunk, so line starts are found across chunk boundaries
+	var sb strings.Builder
+	for sb.Len() < 2*largeFileChunkSize {
+		sb.WriteString("line ")
+		sb.WriteString(strings.Repeat("x", sb.Len()%61))
+		sb.WriteString("\r\n")
+	}
+	sb.WriteString("last")
+	text := sb.String()
+	lf := openIndexed(t, []byte(text))
+
+	want := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if lf.LineCount() != len(want) || lf.Progress() != 1 {
+		t.Fatalf("%d lines indexed, %v of the file, want %d", lf.LineCount(), lf.Progress(), len(want))
+	}
+	for _, row := range []int{0, 1, 7, len(want) / 2, len(want) - 2, len(want) - 1} {
+		if got := lf.Line(row); got != want[row] {
+			t.Errorf("Line(%d) = %q, want %q", row, got, want[row])
+		}
+	}
+	if got := lf.Line(len(want)); got != "" {
+		t.Errorf("Line past the end = %q", got)
+	}
+}
+
+func TestLargeFileEncodings(t *testing.T) {
+	for _, enc := range []Encoding{
+		{Charset: CharsetUTF8, BOM: true},
+		{Charset: CharsetUTF16LE, BOM: true},
+		{Charset: CharsetUTF16BE, BOM: true},
+	} {
+		t.Run(enc.String(), func(t *testing.T) {
+			// In UTF-16, ਊĀਊ holds the bytes of a newline between two code units
+			data, err := Encode("héllo\nਊĀਊ wörld\n", enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lf := openIndexed(t, data)
+			want := []string{"héllo", "ਊĀਊ wörld", ""}
+			if lf.LineCount() != len(want) {
+				t.Fatalf("%d lines, want %d", lf.LineCount(), len(want))
+			}
+			for row, line := range want {
+				if got := lf.Line(row); got != line {
+					t.Errorf("Line(%d) = %q, want %q", row, got, line)
+				}
+			}
+		})
+	}
+}
+
+func TestLargeFileLongLine(t *testing.T) {
+	lf := openIndexed(t, []byte(strings.Repeat("a", largeFileMaxLine+10)+"\nshort"))
+	if got := lf.Line(0); got != strings.Repeat("a", largeFileMaxLine)+"…" {
+		t.Errorf("long line is %d bytes, ending in %q", len(got), got[len(got)-5:])
+	}
+	if got := lf.Line(1); got != "short" {
+		t.Errorf("Line(1) = %q, want \"short\"", got)
+	}
+}
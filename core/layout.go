@@ -0,0 +1,121 @@
+package core
+
+import (
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// DefaultTabWidth is how many columns apart tab stops are unless a Layout
+// says otherwise.
+const DefaultTabWidth = 4
+
+// Layout describes how lines are laid out on screen, in visual columns: a
+// tab reaches the next tab stop, and a wide character such as a CJK
+// ideograph or an emoji takes two columns.
+type Layout struct {
+	TabWidth  int // Columns between tab stops, DefaultTabWidth if 0
+	WrapWidth int // Lines longer than this many columns are soft-wrapped, 0 for no wrapping
+}
+
+// Width returns how many columns cluster takes when it starts at visual
+// column vcol.
+func (l Layout) Width(cluster string, vcol int) int {
+	if cluster == "\t" {
+		tab := l.TabWidth
+		if tab <= 0 {
+			tab = DefaultTabWidth
+		}
+		return tab - vcol%tab
+	}
+	return uniseg.StringWidth(cluster)
+}
+
+// cell is where a cursor position on a line ends up on screen.
+type cell struct {
+	col   int // Rune column in the line
+	vline int // Visual line within the line, 0 unless it is wrapped
+	vcol  int // Visual column within the visual line
+}
+
+// cells returns the screen position of every grapheme cluster boundary of
+// line, up to and including the end. A boundary where the line wraps is at
+// the start of the next visual line.
+func (l Layout) cells(line string) []cell {
+	return l.clusterCells(GraphemeClusters(line))
+}
+
+// clusterCells is cells for a line already split into grapheme clusters.
+func (l Layout) clusterCells(clusters []string) []cell {
+	cells := []cell{{}}
+	cur := cell{}
+	for _, cluster := range clusters {
+		w := l.Width(cluster, cur.vcol)
+		if l.WrapWidth > 0 && cur.vcol > 0 && cur.vcol+w > l.WrapWidth {
+			cur.vline++
+			cur.vcol = 0
+			cells[len(cells)-1] = cur
+			w = l.Width(cluster, 0)
+		}
+		cur.col += utf8.RuneCountInString(cluster)
+		cur.vcol += w
+		cells = append(cells, cur)
+	}
+	return cells
+}
+
+// Breaks returns the rune columns at which the soft-wrapped continuation
+// lines of line start, in order. It is empty if the line fits.
+func (l Layout) Breaks(line string) []int {
+	return breaks(l.cells(line))
+}
+
+// breaks returns the columns at which the visual lines after the first
+// start, given the cells of a line.
+func breaks(cells []cell) []int {
+	var breaks []int
+	for i := 1; i < len(cells); i++ {
+		if cells[i].vline != cells[i-1].vline {
+			breaks = append(breaks, cells[i].col)
+		}
+	}
+	return breaks
+}
+
+// VisualCol returns the visual line and column at which rune column col of
+// line row is shown.
+func (b *Buffer) VisualCol(l Layout, row, col int) (vline, vcol int) {
+	cells := l.cells(b.Line(row))
+	at := cells[0]
+	for _, c := range cells {
+		if c.col > col {
+			break
+		}
+		at = c
+	}
+	return at.vline, at.vcol
+}
+
+// colAtVisual returns the cursor position on visual line vline of row that
+// is closest to visual column vcol, preferring the left one on a tie.
+func (b *Buffer) colAtVisual(l Layout, row, vline, vcol int) int {
+	best, bestDist := -1, 0
+	for _, c := range l.cells(b.Line(row)) {
+		if c.vline != vline {
+			continue
+		}
+		dist := c.vcol - vcol
+		if dist < 0 {
+			dist = -dist
+		}
+		if best < 0 || dist < bestDist {
+			best, bestDist = c.col, dist
+		}
+	}
+	return max(best, 0)
+}
+
+// lastVisualLine returns the index of the last visual line of row.
+func (b *Buffer) lastVisualLine(l Layout, row int) int {
+	return len(l.Breaks(b.Line(row)))
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVisualCol(t *testing.T) {
+	b := NewBuffer()
+	b.Load("a\tb\n世界x\n\t\tab\na🇩🇪x")
+	tests := []struct {
+		row, col    int
+		vline, vcol int
+		layout      Layout
+	}{
+		{0, 1, 0, 1, Layout{}},
+		{0, 2, 0, 4, Layout{}}, // A tab reaches the next tab stop
+		{0, 2, 0, 8, Layout{TabWidth: 8}},
+		{1, 1, 0, 2, Layout{}}, // Wide runes take two columns
+		{1, 3, 0, 5, Layout{}},
+		{2, 2, 0, 8, Layout{}},
+		{2, 3, 1, 1, Layout{WrapWidth: 8}}, // The wrapped part starts at column 0
+		{3, 2, 0, 1, Layout{}},             // Inside the flag: at its start
+		{3, 3, 0, 3, Layout{}},
+	}
+	for _, tt := range tests {
+		if vline, vcol := b.VisualCol(tt.layout, tt.row, tt.col); vline != tt.vline || vcol != tt.vcol {
+			t.Errorf("VisualCol(%+v, %d, %d) = %d, %d, want %d, %d", tt.layout, tt.row, tt.col, vline, vcol, tt.vline, tt.vcol)
+		}
+	}
+}
+
+func TestBreaks(t *testing.T) {
+	tests := []struct {
+		line   string
+		layout Layout
+		want   []int
+	}{
+		{"abcdefghij", Layout{WrapWidth: 4}, []int{4, 8}},
+		{"abcd", Layout{WrapWidth: 4}, nil},
+		{"ab世界", Layout{WrapWidth: 4}, []int{3}}, // A wide rune doesn't fit in the last column
+		{"a\tbc", Layout{WrapWidth: 4}, []int{2}},
+		{"abcdefghij", Layout{}, nil},
+	}
+	for _, tt := range tests {
+		if got := tt.layout.Breaks(tt.line); !slices.Equal(got, tt.want) {
+			t.Errorf("Breaks(%q) with %+v = %v, want %v", tt.line, tt.layout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LineLayout is how one line is laid out on screen.
+type LineLayout struct {
+	Clusters []string // The line split into grapheme clusters
+	Breaks   []int    // Rune columns at which continuation lines start, see Layout.Breaks
+}
+
+// LayoutCache keeps the layout of the lines of a buffer for drawing, so a
+// frame only splits and wraps lines that changed since the last one. It
+// follows the buffer's change events, and lays out nothing until asked.
+type LayoutCache struct {
+	buffer      *Buffer
+	layout      Layout
+	lines       []*LineLayout // Per row, nil until laid out
+	heights     []int         // Visual lines per row, 0 until known
+	tops        []int         // Visual line each row starts at, for the first known rows
+	known       int
+	unsubscribe func()
+}
+
+// NewLayoutCache starts caching the layout of the lines of buffer. Call
+// Close when it is no longer needed.
+func NewLayoutCache(buffer *Buffer, l Layout) *LayoutCache {
+	c := &LayoutCache{buffer: buffer, layout: l}
+	c.reset()
+	c.unsubscribe = buffer.Subscribe(c.changed)
+	return c
+}
+
+// Close stops following the buffer.
+func (c *LayoutCache) Close() {
+	c.unsubscribe()
+}
+
+// SetLayout lays lines out with l from now on, forgetting every line laid
+// out differently.
+func (c *LayoutCache) SetLayout(l Layout) {
+	if l != c.layout {
+		c.layout = l
+		c.reset()
+	}
+}
+
+func (c *LayoutCache) reset() {
+	c.lines = make([]*LineLayout, c.buffer.LineCount())
+	c.heights = make([]int, c.buffer.LineCount())
+	c.tops = make([]int, c.buffer.LineCount())
+	c.known = 1 // The first row starts at the top
+}
+
+// Line returns the layout of line row.
+func (c *LayoutCache) Line(row int) *LineLayout {
+	if ll := c.lines[row]; ll != nil {
+		return ll
+	}
+	clusters := GraphemeClusters(c.buffer.Line(row))
+	ll := &LineLayout{Clusters: clusters, Breaks: breaks(c.layout.clusterCells(clusters))}
+	c.lines[row] = ll
+	c.heights[row] = len(ll.Breaks) + 1
+	return ll
+}
+
+// Height returns how many visual lines line row takes. A line that is
+// clearly too short to wrap isn't laid out to find out.
+func (c *LayoutCache) Height(row int) int {
+	if c.heights[row] == 0 {
+		if c.fits(c.buffer.Line(row)) {
+			c.heights[row] = 1
+		} else {
+			c.Line(row)
+		}
+	}
+	return c.heights[row]
+}
+
+// Top returns the visual line at which row starts, counting from the top of
+// the text. Only the rows above it that haven't been measured since the
+// last change above them are measured.
+func (c *LayoutCache) Top(row int) int {
+	for ; c.known <= row; c.known++ {
+		c.tops[c.known] = c.tops[c.known-1] + c.Height(c.known-1)
+	}
+	return c.tops[row]
+}
+
+// RowAt returns the row shown on visual line vline: the first row for lines
+// above the text and the last one for lines below it.
+func (c *LayoutCache) RowAt(vline int) int {
+	for c.known < len(c.tops) && c.tops[c.known-1] <= vline {
+		c.Top(c.known)
+	}
+	return max(sort.Search(c.known, func(i int) bool { return c.tops[i] > vline })-1, 0)
+}
+
+// fits reports whether line is certain to fit within the wrap width,
+// bounding its width without splitting it into clusters: no cluster is
+// wider than two columns, or than a tab.
+func (c *LayoutCache) fits(line string) bool {
+	if c.layout.WrapWidth <= 0 {
+		return true
+	}
+	tab := c.layout.TabWidth
+	if tab <= 0 {
+		tab = DefaultTabWidth
+	}
+	width := 0
+	for _, r := range line {
+		switch {
+		case r == '\t':
+			width += tab
+		case r < utf8.RuneSelf:
+			width++
+		default:
+			width += 2
+		}
+		if width > c.layout.WrapWidth {
+			return false
+		}
+	}
+	return true
+}
+
+// changed forgets the lines a change replaced, and where the rows after them
+// start. The rows it covered are found from where it starts and how the line
+// count moved.
+func (c *LayoutCache) changed(e ChangeEvent) {
+	row, _ := c.buffer.OffsetToPos(e.Range.Start)
+	added := strings.Count(e.Text, "\n")
+	removed := len(c.lines) - c.buffer.LineCount() + added
+	end := min(row+removed+1, len(c.lines))
+	c.lines = slices.Replace(c.lines, row, end, make([]*LineLayout, added+1)...)
+	c.heights = slices.Replace(c.heights, row, end, make([]int, added+1)...)
+	c.tops = slices.Replace(c.tops, row+1, end, make([]int, added)...)
+	c.known = min(c.known, row+1)
+}
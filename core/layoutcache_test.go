@@ -0,0 +1,93 @@
+package core
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLayoutCacheRows(t *testing.T) {
+	b := NewBuffer()
+	b.Load("short\nthis line wraps twice here\n\n世界世界世界\tx")
+	c := NewLayoutCache(b, Layout{WrapWidth: 10})
+	defer c.Close()
+
+	tests := []struct {
+		row, height, top int
+	}{
+		{0, 1, 0},
+		{1, 3, 1},
+		{2, 1, 4},
+		{3, 2, 5}, // Wide runes take two columns each
+	}
+	for _, tt := range tests {
+		if got := c.Height(tt.row); got != tt.height {
+			t.Errorf("Height(%d) = %d, want %d", tt.row, got, tt.height)
+		}
+		if got := c.Top(tt.row); got != tt.top {
+			t.Errorf("Top(%d) = %d, want %d", tt.row, got, tt.top)
+		}
+	}
+
+	rows := []struct{ vline, row int }{{-1, 0}, {0, 0}, {1, 1}, {3, 1}, {4, 2}, {5, 3}, {6, 3}, {100, 3}}
+	for _, tt := range rows {
+		if got := c.RowAt(tt.vline); got != tt.row {
+			t.Errorf("RowAt(%d) = %d, want %d", tt.vline, got, tt.row)
+		}
+	}
+}
+
+func TestLayoutCacheFollowsEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	b := NewBuffer()
+	b.Load("short\n\tx\nsome longer line that wraps\né🇩🇪 wide 世界世界世界\n")
+	l := Layout{WrapWidth: 10}
+	c := NewLayoutCache(b, l)
+	defer c.Close()
+	texts := []string{"x", "\n", "ab\ncd", "世", "\t", "long long long", "\xff"}
+	for i := 0; i < 2000; i++ {
+		switch rng.Intn(4) {
+		case 0:
+			if b.Len() > 0 {
+				start := rng.Intn(b.Len())
+				b.Delete(Range{Start: start, End: min(b.Len(), start+rng.Intn(8)+1)})
+			}
+		case 1:
+			b.Begin()
+			b.Insert(0, texts[rng.Intn(len(texts))])
+			b.Insert(b.Len(), texts[rng.Intn(len(texts))])
+			b.Commit()
+		case 2:
+			b.Undo()
+		default:
+			b.Insert(rng.Intn(b.Len()+1), texts[rng.Intn(len(texts))])
+		}
+
+		// Only some rows are looked at, so stale ones have a chance to survive
+		top := 0
+		for row := 0; row < b.LineCount(); row++ {
+			want := l.Breaks(b.Line(row))
+			if rng.Intn(3) == 0 {
+				if got := c.Top(row); got != top {
+					t.Fatalf("edit %d: row %d starts at %d, want %d", i, row, got, top)
+				}
+				if got := c.RowAt(top + len(want)); got != row {
+					t.Fatalf("edit %d: RowAt(%d) = %d, want %d", i, top+len(want), got, row)
+				}
+			}
+			if rng.Intn(3) == 0 {
+				if ll := c.Line(row); !slices.Equal(ll.Breaks, want) || strings.Join(ll.Clusters, "") != b.Line(row) {
+					t.Fatalf("edit %d: stale layout of row %d %q", i, row, b.Line(row))
+				}
+			}
+			top += len(want) + 1
+		}
+	}
+
+	narrow := Layout{WrapWidth: 3}
+	c.SetLayout(narrow)
+	if got, want := c.Height(0), len(narrow.Breaks(b.Line(0)))+1; got != want {
+		t.Errorf("Height(0) = %d after narrowing, want %d", got, want)
+	}
+}
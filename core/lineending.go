@@ -0,0 +1,121 @@
+package core
+
+import (
+	"slices"
+	"strings"
+)
+
+// LineEnding is the line ending convention of a file.
+type LineEnding int
+
+const (
+	LineEndingLF    LineEnding = iota // Unix, "\n"
+	LineEndingCRLF                    // Windows, "\r\n"
+	LineEndingMixed                   // both appear in the file
+)
+
+func (le LineEnding) String() string {
+	switch le {
+	case LineEndingCRLF:
+		return "CRLF"
+	case LineEndingMixed:
+		return "Mixed"
+	default:
+		return "LF"
+	}
+}
+
+// splitLineEndings removes the \r from every \r\n in content and reports for
+// each line whether it ended in \r\n. Lone \r characters are left alone.
+func splitLineEndings(content string) (string, []bool) {
+	crlf := make([]bool, strings.Count(content, "\n")+1)
+	if !strings.Contains(content, "\r\n") {
+		return content, crlf
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(content))
+	for i := 0; ; i++ {
+		end := strings.IndexByte(content, '\n')
+		if end < 0 {
+			sb.WriteString(content)
+			break
+		}
+		line := content[:end]
+		if strings.HasSuffix(line, "\r") {
+			line = line[:len(line)-1]
+			crlf[i] = true
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+		content = content[end+1:]
+	}
+	return sb.String(), crlf
+}
+
+// mostlyCRLF reports whether most line breaks in a file are \r\n, which
+// makes that the ending for lines added while editing.
+func mostlyCRLF(crlf []bool) bool {
+	count := 0
+	for _, c := range crlf[:len(crlf)-1] {
+		if c {
+			count++
+		}
+	}
+	return count*2 > len(crlf)-1
+}
+
+// LineEnding reports which line ending the file uses. A file without any
+// line breaks reports the ending new lines will get.
+func (b *Buffer) LineEnding() LineEnding {
+	crlf := b.lines.breaksCRLF()
+	lines := b.lines.count() - 1 // the last line has no ending
+	switch {
+	case lines == 0 && b.lines.defaultCRLF, lines > 0 && crlf == lines:
+		return LineEndingCRLF
+	case crlf == 0:
+		return LineEndingLF
+	default:
+		return LineEndingMixed
+	}
+}
+
+// SetLineEnding converts every line, and lines added later, to le. The
+// conversion is a change of its own in the undo history, even though the
+// text stays the same. LineEndingMixed is ignored.
+func (b *Buffer) SetLineEnding(le LineEnding) {
+	if le == LineEndingMixed {
+		return
+	}
+	before := b.lines.endings()
+	after := make([]bool, len(before))
+	for i := range after {
+		after[i] = le == LineEndingCRLF
+	}
+	if !slices.Equal(before, after) {
+		b.record(Edit{DeletedCRLF: before, InsertedCRLF: after})
+	}
+}
+
+// TextWithLineEndings returns the text with each line's original ending put
+// back, for saving.
+func (b *Buffer) TextWithLineEndings() string {
+	if b.LineEnding() == LineEndingLF {
+		return b.String()
+	}
+
+	var sb strings.Builder
+	sb.Grow(b.Len() + b.LineCount())
+	for i := 0; i < b.LineCount(); i++ {
+		sb.WriteString(b.Line(i))
+		if i == b.LineCount()-1 {
+			break
+		}
+		if b.lines.crlf[i] {
+			sb.WriteString("\r\n")
+		} else {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestLineEndingsSurviveUndo(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edit    func(b *Buffer)
+		want    string
+	}{
+		{"delete crlf", "a\nb\r\nc", func(b *Buffer) { b.Delete(Range{Start: 3, End: 4}) }, "a\nbc"},
+		{"delete mixed lines", "a\r\nb\nc\r\nd", func(b *Buffer) { b.Delete(Range{Start: 0, End: 6}) }, "d"},
+		{"convert to lf", "a\nb\r\nc", func(b *Buffer) { b.SetLineEnding(LineEndingLF) }, "a\nb\nc"},
+		{"convert to crlf", "a\nb\r\nc", func(b *Buffer) { b.SetLineEnding(LineEndingCRLF) }, "a\r\nb\r\nc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load(tt.content)
+			tt.edit(b)
+			if got := b.TextWithLineEndings(); got != tt.want {
+				t.Fatalf("after edit: %q, want %q", got, tt.want)
+			}
+			if !b.Undo() {
+				t.Fatal("nothing to undo")
+			}
+			if got := b.TextWithLineEndings(); got != tt.content {
+				t.Fatalf("after undo: %q, want %q", got, tt.content)
+			}
+			b.Redo()
+			if got := b.TextWithLineEndings(); got != tt.want {
+				t.Fatalf("after redo: %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineEnding(t *testing.T) {
+	tests := []struct {
+		content string
+		want    LineEnding
+	}{
+		{"", LineEndingLF},
+		{"a\nb", LineEndingLF},
+		{"a\r\nb\r\n", LineEndingCRLF},
+		{"a\r\nb\nc", LineEndingMixed},
+	}
+	for _, tt := range tests {
+		b := NewBuffer()
+		b.Load(tt.content)
+		if got := b.LineEnding(); got != tt.want {
+			t.Errorf("LineEnding() of %q = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+// TestLineEndingFollowsEdits checks the running count of \r\n breaks against
+// the endings of every line after random edits, undos and conversions.
+func TestLineEndingFollowsEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	b := NewBuffer()
+	b.Load("a\r\nbb\nccc\r\n\r\ndddd\ne")
+	texts := []string{"x", "\n", "ab\ncd", "\n\n"}
+	for i := 0; i < 3000; i++ {
+		switch rng.Intn(8) {
+		case 0:
+			b.SetLineEnding(LineEnding(rng.Intn(2)))
+		case 1:
+			b.Undo()
+		case 2, 3:
+			if b.Len() > 0 {
+				start := rng.Intn(b.Len())
+				b.Delete(Range{Start: start, End: min(b.Len(), start+rng.Intn(6)+1)})
+			}
+		default:
+			b.Insert(rng.Intn(b.Len()+1), texts[rng.Intn(len(texts))])
+		}
+
+		breaks := b.LineCount() - 1
+		crlf := 0
+		for _, c := range b.lines.crlf[:breaks] {
+			if c {
+				crlf++
+			}
+		}
+		want := LineEndingMixed
+		switch {
+		case breaks == 0 && b.lines.defaultCRLF, breaks > 0 && crlf == breaks:
+			want = LineEndingCRLF
+		case crlf == 0:
+			want = LineEndingLF
+		}
+		if got := b.LineEnding(); got != want {
+			t.Fatalf("edit %d: LineEnding() = %v with %d of %d breaks \\r\\n, want %v", i, got, crlf, breaks, want)
+		}
+	}
+}
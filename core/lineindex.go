@@ -0,0 +1,172 @@
+package core
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// lineIndex keeps the byte offset at which every line starts and how the
+// line ended in the file. It is updated in place on each edit, so finding a
+// line never needs a scan of the text.
+//
+// An edit moves every line after it. Rather than updating all of those
+// starts, the move is kept as a step that applies from line stepFrom on, and
+// only the starts between the old and the new stepFrom are updated when the
+// next edit is elsewhere. Typing on one line thus costs the same however
+// many lines follow it.
+type lineIndex struct {
+	starts      []int  // starts[0] is always 0; use start, which adds the step
+	crlf        []bool // whether each line ends in \r\n when saved
+	crlfCount   int    // number of true entries in crlf
+	defaultCRLF bool   // ending given to new lines
+
+	stepFrom int // First line whose entry in starts still lacks step
+	step     int
+}
+
+func newLineIndex(content string) *lineIndex {
+	idx := &lineIndex{starts: []int{0}, crlf: []bool{false}}
+	idx.insert(0, content, nil)
+	return idx
+}
+
+// count returns the number of lines. An empty text has one empty line.
+func (idx *lineIndex) count() int {
+	return len(idx.starts)
+}
+
+// start returns the byte offset at which line i starts.
+func (idx *lineIndex) start(i int) int {
+	if i >= idx.stepFrom {
+		return idx.starts[i] + idx.step
+	}
+	return idx.starts[i]
+}
+
+// after returns the first line that starts after offset.
+func (idx *lineIndex) after(offset int) int {
+	return sort.Search(len(idx.starts), func(i int) bool { return idx.start(i) > offset })
+}
+
+// shift moves the start of every line from i on by delta, updating only the
+// starts between the previous stepFrom and i.
+func (idx *lineIndex) shift(i, delta int) {
+	if idx.step != 0 {
+		for ; idx.stepFrom < i; idx.stepFrom++ {
+			idx.starts[idx.stepFrom] += idx.step
+		}
+		for idx.stepFrom > i {
+			idx.stepFrom--
+			idx.starts[idx.stepFrom] -= idx.step
+		}
+	}
+	idx.stepFrom = i
+	idx.step += delta
+}
+
+// lineRange returns the byte range of line i, without its newline, in a
+// text of the given length.
+func (idx *lineIndex) lineRange(i, length int) Range {
+	if i < 0 || i >= idx.count() {
+		return Range{Start: length, End: length}
+	}
+	end := length
+	if i+1 < idx.count() {
+		end = idx.start(i+1) - 1
+	}
+	return Range{Start: idx.start(i), End: end}
+}
+
+// lineAt returns the line containing offset.
+func (idx *lineIndex) lineAt(offset int) int {
+	return idx.after(offset) - 1
+}
+
+// insert records that text was inserted at offset, its line breaks ending
+// as crlf says, or in the default ending if crlf is nil. It returns the
+// endings the breaks got.
+func (idx *lineIndex) insert(offset int, text string, crlf []bool) []bool {
+	if text == "" {
+		return nil
+	}
+	i := idx.after(offset)
+	idx.shift(i, len(text))
+
+	var added []int // Stored without the step, like the starts after them
+	for k := strings.IndexByte(text, '\n'); k >= 0; {
+		added = append(added, offset+k+1-idx.step)
+		next := strings.IndexByte(text[k+1:], '\n')
+		if next < 0 {
+			break
+		}
+		k += next + 1
+	}
+	idx.starts = slices.Insert(idx.starts, i, added...)
+
+	// The line that was split keeps its ending on its last part; the
+	// inserted breaks end the lines before it.
+	if len(added) == 0 {
+		return nil
+	}
+	endings := make([]bool, len(added))
+	for k := range endings {
+		endings[k] = idx.defaultCRLF
+		if k < len(crlf) {
+			endings[k] = crlf[k]
+		}
+		if endings[k] {
+			idx.crlfCount++
+		}
+	}
+	idx.crlf = slices.Insert(idx.crlf, i-1, endings...)
+	return endings
+}
+
+// delete records that the bytes in [start, end) were removed, returning the
+// endings of the line breaks among them.
+func (idx *lineIndex) delete(start, end int) []bool {
+	if start >= end {
+		return nil
+	}
+	i := idx.after(start) // lines whose newline was removed...
+	j := idx.after(end)   // ...end before this one
+	idx.shift(j, start-end)
+	removed := slices.Clone(idx.crlf[i-1 : j-1])
+	for _, c := range removed {
+		if c {
+			idx.crlfCount--
+		}
+	}
+	idx.starts = slices.Delete(idx.starts, i, j)
+	idx.crlf = slices.Delete(idx.crlf, i-1, j-1) // the joined line keeps the last ending
+	idx.stepFrom = i                             // Where line j has moved to
+	return removed
+}
+
+// endings returns the ending of every line followed by the default ending,
+// as kept by a line ending conversion.
+func (idx *lineIndex) endings() []bool {
+	return append(slices.Clone(idx.crlf), idx.defaultCRLF)
+}
+
+// setEndings restores line endings returned by endings.
+func (idx *lineIndex) setEndings(crlf []bool) {
+	copy(idx.crlf, crlf)
+	idx.defaultCRLF = crlf[len(crlf)-1]
+	idx.crlfCount = 0
+	for _, c := range idx.crlf {
+		if c {
+			idx.crlfCount++
+		}
+	}
+}
+
+// breaksCRLF returns the number of line breaks ending in \r\n. The last
+// line has no break, so its entry in crlf is left out.
+func (idx *lineIndex) breaksCRLF() int {
+	if idx.crlf[len(idx.crlf)-1] {
+		return idx.crlfCount - 1
+	}
+	return idx.crlfCount
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lineStarts returns the offset of every line of text, the slow way.
+func lineStarts(text string) []int {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// checkLines compares the line index of b with its text.
+func checkLines(t *testing.T, b *Buffer) {
+	t.Helper()
+	want := lineStarts(b.String())
+	if b.LineCount() != len(want) {
+		t.Fatalf("LineCount() = %d, want %d", b.LineCount(), len(want))
+	}
+	for i, start := range want {
+		if got := b.lines.start(i); got != start {
+			t.Fatalf("line %d starts at %d, want %d", i, got, start)
+		}
+	}
+	for i, line := range strings.Split(b.String(), "\n") {
+		if got := b.Line(i); got != line {
+			t.Fatalf("Line(%d) = %q, want %q", i, got, line)
+		}
+	}
+}
+
+func TestLineIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edit    func(b *Buffer)
+		want    []string
+	}{
+		{"empty", "", func(b *Buffer) {}, []string{""}},
+		{"trailing newline", "a\n", func(b *Buffer) {}, []string{"a", ""}},
+		{"insert line break", "ab", func(b *Buffer) { b.Insert(1, "\n") }, []string{"a", "b"}},
+		{"insert lines", "ad", func(b *Buffer) { b.Insert(1, "\nb\nc\n") }, []string{"a", "b", "c", "d"}},
+		{"delete line break", "a\nb\nc", func(b *Buffer) { b.Delete(Range{Start: 1, End: 2}) }, []string{"ab", "c"}},
+		{"delete lines", "a\nb\nc\nd", func(b *Buffer) { b.Delete(Range{Start: 1, End: 5}) }, []string{"a", "d"}},
+		{"edit before lines", "a\nb\nc", func(b *Buffer) { b.Insert(0, "xyz") }, []string{"xyza", "b", "c"}},
+		{"edit after lines", "a\nb\nc", func(b *Buffer) { b.Insert(5, "xyz") }, []string{"a", "b", "cxyz"}},
+		{"edits in several places", "a\nb\nc\nd", func(b *Buffer) {
+			b.Insert(6, "\n")
+			b.Insert(0, "x\n")
+			b.Delete(Range{Start: 5, End: 7})
+		}, []string{"x", "a", "b", "", "d"}},
+		{"crlf", "a\r\nb\r\n", func(b *Buffer) { b.Insert(2, "c") }, []string{"a", "cb", ""}}, // Offsets count "\n" only
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load(tt.content)
+			tt.edit(b)
+			checkLines(t, b)
+			var got []string
+			for i := 0; i < b.LineCount(); i++ {
+				got = append(got, b.Line(i))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffsetToPos(t *testing.T) {
+	b := NewBuffer()
+	b.Load("ab\nçd\n\nef")
+	tests := []struct {
+		offset   int
+		row, col int
+	}{
+		{0, 0, 0},
+		{2, 0, 2},
+		{3, 1, 0},
+		{5, 1, 1}, // ç is two bytes but one rune
+		{7, 2, 0},
+		{8, 3, 0},
+		{10, 3, 2},
+	}
+	for _, tt := range tests {
+		row, col := b.OffsetToPos(tt.offset)
+		if row != tt.row || col != tt.col {
+			t.Errorf("OffsetToPos(%d) = %d, %d, want %d, %d", tt.offset, row, col, tt.row, tt.col)
+		}
+		if got := b.PosToOffset(tt.row, tt.col); got != tt.offset {
+			t.Errorf("PosToOffset(%d, %d) = %d, want %d", tt.row, tt.col, got, tt.offset)
+		}
+	}
+}
+
+// TestLineIndexRandom checks the line index against the text after random
+// edits and undos, which leave the lazily shifted line starts in every state.
+func TestLineIndexRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	b := NewBuffer()
+	b.Load("a\nbb\nccc\n\ndddd\ne")
+	texts := []string{"x", "\n", "ab\ncd", "\n\n", "é\n"}
+	for i := 0; i < 3000; i++ {
+		if rng.Intn(3) == 0 && b.Len() > 0 {
+			start := rng.Intn(b.Len())
+			b.Delete(Range{Start: start, End: min(b.Len(), start+rng.Intn(6)+1)})
+		} else {
+			b.Insert(rng.Intn(b.Len()+1), texts[rng.Intn(len(texts))])
+		}
+		if i%7 == 0 {
+			b.Undo()
+		}
+		checkLines(t, b)
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+// Stick decides where a mark ends up when text is inserted exactly at it.
+type Stick int
+
+const (
+	StickLeft  Stick = iota // The mark stays before text inserted at it
+	StickRight              // The mark moves past text inserted at it, like a cursor
+)
+
+// Mark is a position in a Buffer that follows the text around it: inserting
+// or deleting text before a mark moves it along, and deleting text around a
+// mark collapses it to where the text was. Cursors, selections and anything
+// else that points into the text should hold marks instead of offsets.
+type Mark struct {
+	Name  string // Empty for anonymous marks
+	Stick Stick
+
+	offset int
+	buffer *Buffer
+}
+
+// NewMark creates an anonymous mark at the byte offset offset. It keeps
+// following edits until it is removed with RemoveMark.
+func (b *Buffer) NewMark(offset int, stick Stick) *Mark {
+	m := &Mark{Stick: stick, offset: b.table.clamp(offset), buffer: b}
+	b.marks = append(b.marks, m)
+	return m
+}
+
+// SetMark moves the mark called name to offset, creating it if needed.
+func (b *Buffer) SetMark(name string, offset int, stick Stick) *Mark {
+	if m := b.namedMarks[name]; m != nil {
+		m.Stick = stick
+		m.Set(offset)
+		return m
+	}
+	m := b.NewMark(offset, stick)
+	m.Name = name
+	if b.namedMarks == nil {
+		b.namedMarks = make(map[string]*Mark)
+	}
+	b.namedMarks[name] = m
+	return m
+}
+
+// Mark returns the mark called name, or nil if there is none.
+func (b *Buffer) Mark(name string) *Mark {
+	return b.namedMarks[name]
+}
+
+// RemoveMark stops m from following edits.
+func (b *Buffer) RemoveMark(m *Mark) {
+	for i, mark := range b.marks {
+		if mark == m {
+			b.marks = append(b.marks[:i], b.marks[i+1:]...)
+			break
+		}
+	}
+	if m.Name != "" && b.namedMarks[m.Name] == m {
+		delete(b.namedMarks, m.Name)
+	}
+}
+
+// shiftMarks moves every mark to where it belongs after deleted bytes at pos
+// were replaced by inserted bytes.
+func (b *Buffer) shiftMarks(pos, deleted, inserted int) {
+	for _, m := range b.marks {
+		switch {
+		case m.offset < pos:
+			// Before the edit, nothing to do
+		case m.offset > pos && m.offset >= pos+deleted:
+			m.offset += inserted - deleted
+		case m.Stick == StickRight:
+			m.offset = pos + inserted
+		default:
+			m.offset = pos
+		}
+	}
+}
+
+// Offset returns the byte offset of the mark.
+func (m *Mark) Offset() int {
+	return m.offset
+}
+
+// Pos returns the row and rune column of the mark.
+func (m *Mark) Pos() (int, int) {
+	return m.buffer.OffsetToPos(m.offset)
+}
+
+// Set moves the mark to the byte offset offset.
+func (m *Mark) Set(offset int) {
+	m.offset = m.buffer.table.clamp(offset)
+}
+
+// SetPos moves the mark to row and rune column col.
+func (m *Mark) SetPos(row, col int) {
+	m.offset = m.buffer.PosToOffset(row, col)
+}
@@ -0,0 +1,83 @@
+package core
+
+import "testing"
+
+func TestMarks(t *testing.T) {
+	tests := []struct {
+		name        string
+		edit        func(b *Buffer)
+		left, right int // Offsets of marks made at 6 sticking left and right
+	}{
+		{"insert before", func(b *Buffer) { b.Insert(0, "xx") }, 8, 8},
+		{"insert after", func(b *Buffer) { b.Insert(8, "xx") }, 6, 6},
+		{"insert at", func(b *Buffer) { b.Insert(6, "big ") }, 6, 10},
+		{"delete before", func(b *Buffer) { b.Delete(Range{Start: 0, End: 2}) }, 4, 4},
+		{"delete after", func(b *Buffer) { b.Delete(Range{Start: 6, End: 8}) }, 6, 6},
+		{"delete around", func(b *Buffer) { b.Delete(Range{Start: 3, End: 9}) }, 3, 3},
+		{"replace around", func(b *Buffer) { b.SetContent("bye") }, 0, 3},
+		{"undo", func(b *Buffer) {
+			b.Delete(Range{Start: 3, End: 9})
+			b.Undo()
+		}, 3, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load("hello\nworld")
+			left := b.NewMark(6, StickLeft)
+			right := b.NewMark(6, StickRight)
+			tt.edit(b)
+			if left.Offset() != tt.left || right.Offset() != tt.right {
+				t.Errorf("marks at %d and %d, want %d and %d", left.Offset(), right.Offset(), tt.left, tt.right)
+			}
+		})
+	}
+}
+
+func TestNamedMarks(t *testing.T) {
+	b := NewBuffer()
+	b.Load("hello\nworld")
+	m := b.SetMark("w", 6, StickLeft)
+	if b.SetMark("w", 8, StickRight) != m || m.Offset() != 8 || m.Stick != StickRight {
+		t.Fatal("SetMark didn't move the existing mark")
+	}
+	if row, col := m.Pos(); row != 1 || col != 2 {
+		t.Errorf("Pos() = %d, %d, want 1, 2", row, col)
+	}
+	m.SetPos(0, 1)
+	if m.Offset() != 1 {
+		t.Errorf("SetPos(0, 1) moved the mark to %d", m.Offset())
+	}
+	b.RemoveMark(m)
+	if b.Mark("w") != nil {
+		t.Error("mark still there after RemoveMark")
+	}
+	b.Insert(0, "x") // A removed mark no longer follows edits
+	if m.Offset() != 1 {
+		t.Errorf("removed mark moved to %d", m.Offset())
+	}
+}
+
+func TestCursorsFollowEdits(t *testing.T) {
+	cm := NewCursorManager()
+	b := NewBuffer()
+	b.TrackCursors(cm)
+	b.Load("abc\ndef")
+	c := cm.GetPrimary()
+	c.Row, c.Col = 1, 1
+	c.Selection = Selection{StartRow: 1, StartCol: 1, EndRow: 1, EndCol: 3, Active: true}
+
+	b.Insert(0, "zz\n")
+	if c.Row != 2 || c.Col != 1 || c.Selection.StartRow != 2 || c.Selection.EndRow != 2 {
+		t.Fatalf("after inserting a line above: %+v", *c)
+	}
+	c.Selection.Active = false
+	InsertAtCursor(b, "Q", c.Row, c.Col)
+	if c.Col != 2 {
+		t.Fatalf("after typing: %+v", *c)
+	}
+	b.Delete(Range{Start: 3, End: 9}) // Around the cursor
+	if c.Row != 1 || c.Col != 0 || b.String() != "zz\nef" {
+		t.Fatalf("after deleting around it: %+v in %q", *c, b.String())
+	}
+}
@@ -0,0 +1,147 @@
+package core
+
+// MoveTo moves the cursor to row and col. With extend the selection grows or
+// shrinks from its anchor, kept in Selection.StartRow and StartCol, which is
+// where the cursor was when the selection started; without it the selection
+// is dropped.
+func (c *Cursor) MoveTo(row, col int, extend bool) {
+	if !extend {
+		c.Selection.Active = false
+	} else if !c.Selection.Active {
+		c.Selection = Selection{StartRow: c.Row, StartCol: c.Col, Active: true}
+	}
+	c.Row, c.Col = row, col
+	if c.Selection.Active {
+		c.Selection.EndRow, c.Selection.EndCol = row, col
+		if c.Selection.StartRow == row && c.Selection.StartCol == col {
+			c.Selection.Active = false
+		}
+	}
+}
+
+// moveCursors moves every cursor to the position to returns for it and
+// merges cursors that meet.
+func (cm *CursorManager) moveCursors(extend bool, to func(c *Cursor) (int, int)) {
+	for i := range cm.Cursors {
+		c := &cm.Cursors[i]
+		row, col := to(c)
+		c.MoveTo(row, col, extend)
+	}
+	cm.Merge()
+}
+
+// MoveLeft moves every cursor one character to the left. Without extend, a
+// cursor with a selection goes to the left edge of it instead.
+func MoveLeft(buffer *Buffer, cm *CursorManager, extend bool) {
+	cm.moveCursors(extend, func(c *Cursor) (int, int) {
+		if start, end := c.span(); !extend && start != end {
+			return start.row, start.col
+		}
+		if c.Col > 0 {
+			return c.Row, buffer.PrevGrapheme(c.Row, c.Col)
+		}
+		return c.Row, c.Col
+	})
+}
+
+// MoveRight moves every cursor one character to the right. Without extend, a
+// cursor with a selection goes to the right edge of it instead.
+func MoveRight(buffer *Buffer, cm *CursorManager, extend bool) {
+	cm.moveCursors(extend, func(c *Cursor) (int, int) {
+		if start, end := c.span(); !extend && start != end {
+			return end.row, end.col
+		}
+		if c.Col < buffer.LineLen(c.Row) {
+			return c.Row, buffer.NextGrapheme(c.Row, c.Col)
+		}
+		return c.Row, c.Col
+	})
+}
+
+// MoveVertical moves every cursor delta visual lines down, or up when
+// negative, where the lines of a soft-wrapped line each count as one. Each
+// cursor aims for the visual column it was in before a run of vertical
+// moves, so passing through a short line doesn't lose it.
+func MoveVertical(buffer *Buffer, cm *CursorManager, l Layout, delta int, extend bool) {
+	for i := range cm.Cursors {
+		c := &cm.Cursors[i]
+		goal := c.goalColumn(buffer, l)
+		row, col := buffer.verticalTarget(l, c.Row, c.Col, goal, delta)
+		c.MoveTo(row, col, extend)
+		c.setGoal(goal)
+	}
+	cm.Merge()
+}
+
+// goalColumn returns the visual column the cursor aims for when moving up
+// or down: its own, unless it got here by moving up or down.
+func (c *Cursor) goalColumn(buffer *Buffer, l Layout) int {
+	if c.hasGoal && c.goalAt == (pos{c.Row, c.Col}) {
+		return c.goal
+	}
+	_, vcol := buffer.VisualCol(l, c.Row, c.Col)
+	return vcol
+}
+
+func (c *Cursor) setGoal(goal int) {
+	c.goal, c.goalAt, c.hasGoal = goal, pos{c.Row, c.Col}, true
+}
+
+// verticalTarget returns the position delta visual lines below row and col
+// closest to visual column goal. If there are not that many lines the
+// position doesn't change.
+func (b *Buffer) verticalTarget(l Layout, row, col, goal, delta int) (int, int) {
+	vline, _ := b.VisualCol(l, row, col)
+	r := row
+	for ; delta < 0; delta++ {
+		if vline > 0 {
+			vline--
+		} else if r > 0 {
+			r--
+			vline = b.lastVisualLine(l, r)
+		} else {
+			return row, col
+		}
+	}
+	for ; delta > 0; delta-- {
+		if vline < b.lastVisualLine(l, r) {
+			vline++
+		} else if r < b.LineCount()-1 {
+			r++
+			vline = 0
+		} else {
+			return row, col
+		}
+	}
+	return r, b.colAtVisual(l, r, vline, goal)
+}
+
+// MoveWordLeft moves every cursor to the start of the word before it, or of
+// the subword with subword set, so fooBar_baz has three parts.
+func MoveWordLeft(buffer *Buffer, cm *CursorManager, subword, extend bool) {
+	cm.moveCursors(extend, func(c *Cursor) (int, int) {
+		return buffer.PrevWord(c.Row, c.Col, subword)
+	})
+}
+
+// MoveWordRight moves every cursor to the end of the word after it, or of
+// the subword with subword set.
+func MoveWordRight(buffer *Buffer, cm *CursorManager, subword, extend bool) {
+	cm.moveCursors(extend, func(c *Cursor) (int, int) {
+		return buffer.NextWord(c.Row, c.Col, subword)
+	})
+}
+
+// MoveLineStart moves every cursor to the start of its line.
+func MoveLineStart(buffer *Buffer, cm *CursorManager, extend bool) {
+	cm.moveCursors(extend, func(c *Cursor) (int, int) {
+		return c.Row, 0
+	})
+}
+
+// MoveLineEnd moves every cursor to the end of its line.
+func MoveLineEnd(buffer *Buffer, cm *CursorManager, extend bool) {
+	cm.moveCursors(extend, func(c *Cursor) (int, int) {
+		return c.Row, buffer.LineLen(c.Row)
+	})
+}
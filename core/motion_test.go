@@ -0,0 +1,100 @@
+package core
+
+import "testing"
+
+func TestShiftSelect(t *testing.T) {
+	b, cm := trackedBuffer("hello\nworld")
+	cm.Cursors[0] = Cursor{Row: 0, Col: 2}
+	steps := []struct {
+		name     string
+		move     func()
+		row, col int
+		selected string
+	}{
+		{"extend right", func() { MoveRight(b, cm, true); MoveRight(b, cm, true) }, 0, 4, "ll"},
+		{"back past the anchor", func() { MoveLeft(b, cm, true); MoveLeft(b, cm, true); MoveLeft(b, cm, true) }, 0, 1, "e"},
+		{"right collapses to the right edge", func() { MoveRight(b, cm, false) }, 0, 2, ""},
+		{"end and down", func() { MoveLineEnd(b, cm, true); MoveVertical(b, cm, Layout{}, 1, true) }, 1, 5, "llo\nworld"},
+		{"left collapses to the left edge", func() { MoveLeft(b, cm, false) }, 0, 2, ""},
+		{"home", func() { MoveLineStart(b, cm, true) }, 0, 0, "he"},
+		{"back to the anchor", func() { MoveRight(b, cm, true); MoveRight(b, cm, true) }, 0, 2, ""},
+		{"plain move", func() { MoveLeft(b, cm, false) }, 0, 1, ""},
+		{"word", func() { MoveWordRight(b, cm, false, true) }, 0, 5, "ello"},
+	}
+	for _, step := range steps {
+		step.move()
+		c := cm.GetPrimary()
+		if c.Row != step.row || c.Col != step.col {
+			t.Fatalf("%s: cursor at %d, %d, want %d, %d", step.name, c.Row, c.Col, step.row, step.col)
+		}
+		if got := cm.GetSelectedText(0, b); got != step.selected || c.Selection.Active != (step.selected != "") {
+			t.Fatalf("%s: selected %q (active %v), want %q", step.name, got, c.Selection.Active, step.selected)
+		}
+	}
+}
+
+func TestShiftSelectMerges(t *testing.T) {
+	b, cm := trackedBuffer("hello\nworld")
+	cm.Cursors = []Cursor{{Row: 1, Col: 1}, {Row: 1, Col: 3}}
+	MoveRight(b, cm, true)
+	MoveRight(b, cm, true)
+	if len(cm.Cursors) != 2 {
+		t.Fatalf("touching selections merged into %v", cm.Cursors)
+	}
+	MoveRight(b, cm, true)
+	if len(cm.Cursors) != 1 || cm.GetSelectedText(0, b) != "orld" {
+		t.Fatalf("overlapping selections left %v", cm.Cursors)
+	}
+}
+
+func TestGoalColumn(t *testing.T) {
+	b, cm := trackedBuffer("abcdefgh\nab\n\nabcdefgh\n\tx\n世界世界")
+	l := Layout{TabWidth: 4}
+	cm.Cursors[0] = Cursor{Row: 0, Col: 6}
+	down := func() { MoveVertical(b, cm, l, 1, false) }
+	up := func() { MoveVertical(b, cm, l, -1, false) }
+	steps := []struct {
+		name     string
+		move     func()
+		row, col int
+	}{
+		{"short line", down, 1, 2},
+		{"empty line", down, 2, 0},
+		{"goal kept", down, 3, 6},
+		{"over a tab", down, 4, 2}, // x is at visual column 4, the end at 5
+		{"wide runes", down, 5, 3},
+		{"left resets the goal", func() { MoveLeft(b, cm, false); up() }, 4, 1},
+		{"back to the start", func() { MoveVertical(b, cm, l, -4, false) }, 0, 4},
+		{"an edit resets the goal", func() { down(); InsertAtCursors(b, cm, "Z"); up() }, 0, 3},
+		{"moving the cursor elsewhere resets it", func() { down(); cm.Cursors[0].Col = 0; up() }, 0, 0},
+	}
+	for _, step := range steps {
+		step.move()
+		if c := cm.GetPrimary(); c.Row != step.row || c.Col != step.col {
+			t.Fatalf("%s: cursor at %d, %d, want %d, %d", step.name, c.Row, c.Col, step.row, step.col)
+		}
+	}
+}
+
+func TestGoalColumnAcrossWraps(t *testing.T) {
+	b, cm := trackedBuffer("abcdefghij\nxy")
+	l := Layout{WrapWidth: 4}
+	cm.Cursors[0] = Cursor{Row: 0, Col: 1}
+	steps := []struct {
+		delta    int
+		row, col int
+	}{
+		{1, 0, 5},
+		{1, 0, 9},
+		{1, 1, 1},
+		{-2, 0, 5},
+		{-1, 0, 1},
+		{-1, 0, 1}, // Already on the first visual line
+	}
+	for _, step := range steps {
+		MoveVertical(b, cm, l, step.delta, false)
+		if c := cm.GetPrimary(); c.Row != step.row || c.Col != step.col {
+			t.Fatalf("MoveVertical(%d): cursor at %d, %d, want %d, %d", step.delta, c.Row, c.Col, step.row, step.col)
+		}
+	}
+}
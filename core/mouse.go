@@ -0,0 +1,85 @@
+package core
+
+// Unit is how much a mouse selection grows by while it is dragged.
+type Unit int
+
+const (
+	UnitChar Unit = iota // Single click
+	UnitWord             // Double click
+	UnitLine             // Triple click
+)
+
+// UnitForClicks returns the unit selected by clicking clicks times in a row.
+func UnitForClicks(clicks int) Unit {
+	switch {
+	case clicks >= 3:
+		return UnitLine
+	case clicks == 2:
+		return UnitWord
+	}
+	return UnitChar
+}
+
+// MouseSelection selects text with the mouse. It remembers what the click
+// that started it selected, so dragging afterwards grows the selection by
+// whole words or lines while keeping that first word or line selected.
+type MouseSelection struct {
+	Unit       Unit
+	start, end pos // What the first click selected
+}
+
+// Click starts a selection with c at row and col: a cursor for a single
+// click, the word for a double click and the line for a triple click. With
+// extend, as for Shift+click, the selection of c is extended to there
+// instead, from its anchor.
+func (m *MouseSelection) Click(buffer *Buffer, c *Cursor, row, col, clicks int, extend bool) {
+	m.Unit = UnitForClicks(clicks)
+	if extend {
+		anchor := pos{c.Row, c.Col}
+		if c.Selection.Active {
+			anchor = pos{c.Selection.StartRow, c.Selection.StartCol}
+		}
+		m.start, m.end = anchor, anchor
+	} else {
+		m.start, m.end = buffer.unitAt(m.Unit, row, col)
+	}
+	m.Drag(buffer, c, row, col)
+}
+
+// Drag extends the selection of c to row and col, rounded out to whole
+// units. The anchor flips to the far side of the first unit when dragging
+// back past its start.
+func (m *MouseSelection) Drag(buffer *Buffer, c *Cursor, row, col int) {
+	start, end := buffer.unitAt(m.Unit, row, col)
+	anchor, head := m.start, end
+	if start.less(m.start) {
+		anchor, head = m.end, start
+	} else if head.less(m.end) {
+		head = m.end
+	}
+	c.Selection = Selection{StartRow: anchor.row, StartCol: anchor.col, EndRow: head.row, EndCol: head.col}
+	c.Selection.Active = anchor != head
+	c.Row, c.Col = head.row, head.col
+}
+
+// unitAt returns the span of the unit at row and col. A word is the word
+// under the position, or the character there when it isn't on a word. A
+// line includes its line break, so dragging over lines selects them whole.
+func (b *Buffer) unitAt(unit Unit, row, col int) (start, end pos) {
+	switch unit {
+	case UnitWord:
+		if s, e, ok := b.WordAt(row, col); ok {
+			return pos{row, s}, pos{row, e}
+		}
+		if col < b.LineLen(row) {
+			col = b.SnapGrapheme(row, col)
+			return pos{row, col}, pos{row, b.NextGrapheme(row, col)}
+		}
+	case UnitLine:
+		if row+1 < b.LineCount() {
+			return pos{row, 0}, pos{row + 1, 0}
+		}
+		return pos{row, 0}, pos{row, b.LineLen(row)}
+	}
+	return pos{row, col}, pos{row, col}
+}
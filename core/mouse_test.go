@@ -0,0 +1,54 @@
+package core
+
+import "testing"
+
+func TestUnitForClicks(t *testing.T) {
+	for clicks, want := range []Unit{UnitChar, UnitChar, UnitWord, UnitLine, UnitLine} {
+		if got := UnitForClicks(clicks); got != want {
+			t.Errorf("UnitForClicks(%d) = %v, want %v", clicks, got, want)
+		}
+	}
+}
+
+func TestMouseSelection(t *testing.T) {
+	b, cm := trackedBuffer("foo barBaz qux\nsecond line\nlast\n\n a  b")
+	c := cm.GetPrimary()
+	var m MouseSelection
+	click := func(row, col, clicks int, extend bool) func() {
+		return func() { m.Click(b, c, row, col, clicks, extend) }
+	}
+	drag := func(row, col int) func() {
+		return func() { m.Drag(b, c, row, col) }
+	}
+	steps := []struct {
+		name         string
+		mouse        func()
+		anchor, head pos // Where the selection starts and the cursor is
+	}{
+		{"single click", click(0, 2, 1, false), pos{0, 2}, pos{0, 2}},
+		{"drag", drag(1, 3), pos{0, 2}, pos{1, 3}},
+		{"double click", click(0, 5, 2, false), pos{0, 4}, pos{0, 10}},
+		{"drag by words", drag(0, 12), pos{0, 4}, pos{0, 14}},
+		{"drag back past the word", drag(0, 1), pos{0, 10}, pos{0, 0}},
+		{"drag back into the word", drag(0, 6), pos{0, 4}, pos{0, 10}},
+		{"double click at the end of a word", click(0, 3, 2, false), pos{0, 0}, pos{0, 3}},
+		{"double click between words", click(4, 3, 2, false), pos{4, 3}, pos{4, 4}},
+		{"double click on an empty line", click(3, 0, 2, false), pos{3, 0}, pos{3, 0}},
+		{"triple click", click(1, 3, 3, false), pos{1, 0}, pos{2, 0}},
+		{"drag by lines", drag(2, 1), pos{1, 0}, pos{3, 0}},
+		{"drag back by lines", drag(0, 5), pos{2, 0}, pos{0, 0}},
+		{"triple click on the last line", click(4, 0, 3, false), pos{4, 0}, pos{4, 5}},
+		{"shift click", click(0, 2, 1, true), pos{4, 0}, pos{0, 2}},
+		{"shift click keeps the anchor", click(1, 1, 1, true), pos{4, 0}, pos{1, 1}},
+	}
+	for _, step := range steps {
+		step.mouse()
+		anchor := pos{c.Row, c.Col}
+		if c.Selection.Active {
+			anchor = pos{c.Selection.StartRow, c.Selection.StartCol}
+		}
+		if head := (pos{c.Row, c.Col}); anchor != step.anchor || head != step.head {
+			t.Fatalf("%s: selected from %v to %v, want %v to %v", step.name, anchor, head, step.anchor, step.head)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package core
+
+import "sort"
+
+// pos is a row and rune column, for ordering cursors.
+type pos struct {
+	row, col int
+}
+
+func (p pos) less(q pos) bool {
+	return p.row < q.row || p.row == q.row && p.col < q.col
+}
+
+// span returns the start and end of the text the cursor covers: its
+// selection, or the empty span at the cursor.
+func (c *Cursor) span() (start, end pos) {
+	start, end = pos{c.Row, c.Col}, pos{c.Row, c.Col}
+	if c.Selection.Active {
+		start = pos{c.Selection.StartRow, c.Selection.StartCol}
+		end = pos{c.Selection.EndRow, c.Selection.EndCol}
+		if end.less(start) {
+			start, end = end, start
+		}
+	}
+	return start, end
+}
+
+// AddCursor adds c and makes it the primary cursor. If it overlaps another
+// cursor the two are merged, and the merged one is returned.
+func (cm *CursorManager) AddCursor(c Cursor) *Cursor {
+	cm.Cursors = append(cm.Cursors, c)
+	cm.PrimaryCursor = len(cm.Cursors) - 1
+	cm.Merge()
+	return cm.GetPrimary()
+}
+
+// ClearSecondary removes every cursor but the primary one.
+func (cm *CursorManager) ClearSecondary() {
+	cm.Cursors = append(cm.Cursors[:0], cm.Cursors[cm.PrimaryCursor])
+	cm.PrimaryCursor = 0
+}
+
+// Merge sorts the cursors by position and turns cursors whose selections
+// overlap, or that sit on the edge of another's selection, into one. The
+// merged cursor covers both selections and is primary if either was.
+func (cm *CursorManager) Merge() {
+	if len(cm.Cursors) < 2 {
+		return
+	}
+	type entry struct {
+		cursor  Cursor
+		primary bool
+	}
+	entries := make([]entry, len(cm.Cursors))
+	for i, c := range cm.Cursors {
+		entries[i] = entry{c, i == cm.PrimaryCursor}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, _ := entries[i].cursor.span()
+		b, _ := entries[j].cursor.span()
+		return a.less(b)
+	})
+
+	merged := entries[:1]
+	for _, e := range entries[1:] {
+		last := &merged[len(merged)-1]
+		lastStart, lastEnd := last.cursor.span()
+		start, end := e.cursor.span()
+		if lastEnd.less(start) || lastEnd == start && lastStart != lastEnd && start != end {
+			merged = append(merged, e)
+			continue
+		}
+		// The merged cursor keeps the direction of the first one's selection
+		c := &last.cursor
+		backward := lastStart != lastEnd && c.Row == lastStart.row && c.Col == lastStart.col
+		end = maxPos(end, lastEnd)
+		*c = Cursor{Row: end.row, Col: end.col}
+		if lastStart != end {
+			c.Selection = Selection{StartRow: lastStart.row, StartCol: lastStart.col, EndRow: end.row, EndCol: end.col, Active: true}
+			if backward {
+				c.Row, c.Col = lastStart.row, lastStart.col
+				c.Selection = Selection{StartRow: end.row, StartCol: end.col, EndRow: lastStart.row, EndCol: lastStart.col, Active: true}
+			}
+		}
+		last.primary = last.primary || e.primary
+	}
+
+	cm.Cursors = cm.Cursors[:0]
+	for i, e := range merged {
+		cm.Cursors = append(cm.Cursors, e.cursor)
+		if e.primary {
+			cm.PrimaryCursor = i
+		}
+	}
+}
+
+func maxPos(a, b pos) pos {
+	if a.less(b) {
+		return b
+	}
+	return a
+}
+
+// IsSelected reports whether the character at row and col is in the
+// selection of any cursor.
+func (cm *CursorManager) IsSelected(row, col int) bool {
+	for _, c := range cm.Cursors {
+		if IsCharacterSelected(row, col, c.Selection) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddCursorVertical adds a cursor one visual line above the topmost cursor,
+// or below the bottommost one when delta is positive, aiming for the same
+// visual column the way MoveVertical does.
+func AddCursorVertical(buffer *Buffer, cm *CursorManager, l Layout, delta int) bool {
+	edge := cm.Cursors[0]
+	for _, c := range cm.Cursors {
+		if delta < 0 && c.Row < edge.Row || delta > 0 && c.Row > edge.Row {
+			edge = c
+		}
+	}
+	goal := edge.goalColumn(buffer, l)
+	row, col := buffer.verticalTarget(l, edge.Row, edge.Col, goal, delta)
+	if row == edge.Row && col == edge.Col {
+		return false
+	}
+	c := Cursor{Row: row, Col: col}
+	c.setGoal(goal)
+	cm.AddCursor(c)
+	return true
+}
+
+// AddNextOccurrence selects the word at the primary cursor if nothing is
+// selected yet, and otherwise adds a cursor selecting the next occurrence of
+// the selected text that isn't selected already. It returns false when
+// there is nothing to add.
+func AddNextOccurrence(buffer *Buffer, cm *CursorManager) bool {
+	primary := cm.GetPrimary()
+	start, end := primary.span()
+	if start == end {
+		from, to, ok := buffer.WordAt(primary.Row, primary.Col)
+		if !ok {
+			return false
+		}
+		primary.Selection = Selection{StartRow: primary.Row, StartCol: from, EndRow: primary.Row, EndCol: to, Active: true}
+		primary.Col = to
+		return true
+	}
+
+	query := GetTextInRange(buffer, start.row, start.col, end.row, end.col)
+	from := buffer.PosToOffset(end.row, end.col)
+	matches := buffer.FindAll(query)
+	first := sort.Search(len(matches), func(i int) bool { return matches[i].Start >= from })
+	for i := range matches {
+		match := matches[(first+i)%len(matches)] // Wrapping around to the start
+		startRow, startCol := buffer.OffsetToPos(match.Start)
+		endRow, endCol := buffer.OffsetToPos(match.End)
+		if cm.selects(pos{startRow, startCol}, pos{endRow, endCol}) {
+			continue
+		}
+		cm.AddCursor(Cursor{
+			Row: endRow, Col: endCol,
+			Selection: Selection{StartRow: startRow, StartCol: startCol, EndRow: endRow, EndCol: endCol, Active: true},
+		})
+		return true
+	}
+	return false
+}
+
+// selects reports whether some cursor selects exactly [start, end).
+func (cm *CursorManager) selects(start, end pos) bool {
+	for i := range cm.Cursors {
+		if s, e := cm.Cursors[i].span(); s == start && e == end {
+			return true
+		}
+	}
+	return false
+}
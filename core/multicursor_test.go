@@ -0,0 +1,186 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+// trackedBuffer returns a buffer holding text and a cursor manager whose
+// cursors follow its edits.
+func trackedBuffer(text string) (*Buffer, *CursorManager) {
+	b := NewBuffer()
+	b.Load(text)
+	cm := NewCursorManager()
+	b.TrackCursors(cm)
+	return b, cm
+}
+
+// sel returns a cursor at to on the first line, selecting back to from
+// unless they are the same.
+func sel(from, to int) Cursor {
+	c := Cursor{Row: 0, Col: to}
+	if from != to {
+		c.Selection = Selection{StartRow: 0, StartCol: from, EndRow: 0, EndCol: to, Active: true}
+	}
+	return c
+}
+
+func TestMultiCursorEditing(t *testing.T) {
+	b, cm := trackedBuffer("aaa\nbbb\nccc")
+	cm.Cursors[0] = Cursor{Row: 0, Col: 1}
+	cm.AddCursor(Cursor{Row: 1, Col: 1})
+	cm.AddCursor(Cursor{Row: 2, Col: 1})
+	if len(cm.Cursors) != 3 || cm.PrimaryCursor != 2 {
+		t.Fatalf("%d cursors, primary %d, want 3 with the last one primary", len(cm.Cursors), cm.PrimaryCursor)
+	}
+
+	InsertAtCursors(b, cm, "X")
+	InsertAtCursors(b, cm, "Y")
+	if b.String() != "aXYaa\nbXYbb\ncXYcc" {
+		t.Fatalf("typed %q", b.String())
+	}
+	for i, c := range cm.Cursors {
+		if c.Row != i || c.Col != 3 {
+			t.Errorf("cursor %d at %d, %d, want %d, 3", i, c.Row, c.Col, i)
+		}
+	}
+	if b.Undo(); b.String() != "aaa\nbbb\nccc" {
+		t.Fatalf("undo: %q, want the typing undone in one step", b.String())
+	}
+	b.Redo()
+
+	DeleteAtCursors(b, cm)
+	DeleteAtCursors(b, cm)
+	if b.String() != "aaa\nbbb\nccc" {
+		t.Fatalf("backspace: %q", b.String())
+	}
+	InsertAtCursors(b, cm, "\n")
+	if b.String() != "a\naa\nb\nbb\nc\ncc" || cm.Cursors[2].Row != 5 || cm.Cursors[2].Col != 0 {
+		t.Fatalf("newlines: %q, last cursor at %d, %d", b.String(), cm.Cursors[2].Row, cm.Cursors[2].Col)
+	}
+}
+
+func TestMultiCursorMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursors []Cursor
+		want    []Cursor
+	}{
+		{"cursors apart", []Cursor{sel(4, 4), sel(1, 1)}, []Cursor{sel(1, 1), sel(4, 4)}},
+		{"same place", []Cursor{sel(2, 2), sel(2, 2)}, []Cursor{sel(2, 2)}},
+		{"cursor inside a selection", []Cursor{sel(1, 3), sel(2, 2)}, []Cursor{sel(1, 3)}},
+		{"cursor on the edge of a selection", []Cursor{sel(1, 3), sel(3, 3)}, []Cursor{sel(1, 3)}},
+		{"overlapping selections", []Cursor{sel(1, 3), sel(2, 4)}, []Cursor{sel(1, 4)}},
+		{"backward selection keeps its direction", []Cursor{sel(2, 0), sel(1, 3)}, []Cursor{sel(3, 0)}},
+		{"touching selections stay apart", []Cursor{sel(0, 2), sel(2, 4)}, []Cursor{sel(0, 2), sel(2, 4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &CursorManager{Cursors: slices.Clone(tt.cursors), PrimaryCursor: len(tt.cursors) - 1}
+			cm.Merge()
+			if !slices.Equal(cm.Cursors, tt.want) {
+				t.Errorf("merged into %v, want %v", cm.Cursors, tt.want)
+			}
+		})
+	}
+
+	// Cursors meeting after an edit are merged too
+	b, cm := trackedBuffer("abcdef")
+	cm.Cursors[0] = Cursor{Row: 0, Col: 1}
+	cm.AddCursor(Cursor{Row: 0, Col: 2})
+	DeleteAtCursors(b, cm)
+	if b.String() != "cdef" || !slices.Equal(cm.Cursors, []Cursor{{Row: 0, Col: 0}}) {
+		t.Errorf("backspace left %q with cursors %v", b.String(), cm.Cursors)
+	}
+}
+
+func TestAddNextOccurrence(t *testing.T) {
+	b, cm := trackedBuffer("foo bar foo\nfoo")
+	cm.Cursors[0] = Cursor{Row: 0, Col: 9}
+	steps := []struct {
+		name     string
+		ok       bool
+		row, col int // Of the primary cursor
+		cursors  int
+	}{
+		{"select the word", true, 0, 11, 1},
+		{"next occurrence", true, 1, 3, 2},
+		{"wrap around", true, 0, 3, 3},
+		{"all taken", false, 0, 3, 3},
+	}
+	for _, step := range steps {
+		ok := AddNextOccurrence(b, cm)
+		p := cm.GetPrimary()
+		if ok != step.ok || p.Row != step.row || p.Col != step.col || len(cm.Cursors) != step.cursors {
+			t.Fatalf("%s: %v, primary at %d, %d of %d cursors, want %v at %d, %d of %d",
+				step.name, ok, p.Row, p.Col, len(cm.Cursors), step.ok, step.row, step.col, step.cursors)
+		}
+	}
+
+	InsertAtCursors(b, cm, "baz")
+	if b.String() != "baz bar baz\nbaz" {
+		t.Fatalf("typed over the occurrences: %q", b.String())
+	}
+	if b.Undo(); b.String() != "foo bar foo\nfoo" || len(cm.Cursors) != 3 || !cm.HasSelection() {
+		t.Fatalf("undo: %q with %d cursors", b.String(), len(cm.Cursors))
+	}
+}
+
+func TestAddCursorVertical(t *testing.T) {
+	b, cm := trackedBuffer("abcdef\nab\nabcdef")
+	cm.Cursors[0] = Cursor{Row: 0, Col: 4}
+	AddCursorVertical(b, cm, Layout{}, 1)
+	AddCursorVertical(b, cm, Layout{}, 1)
+	if AddCursorVertical(b, cm, Layout{}, 1) {
+		t.Error("added a cursor below the last line")
+	}
+	if AddCursorVertical(b, cm, Layout{}, -1) {
+		t.Error("added a cursor above the first line")
+	}
+	cols := []int{4, 2, 4} // The short line doesn't lose the column
+	for i, c := range cm.Cursors {
+		if c.Row != i || c.Col != cols[i] {
+			t.Errorf("cursor %d at %d, %d, want %d, %d", i, c.Row, c.Col, i, cols[i])
+		}
+	}
+
+	cm.ClearSecondary()
+	if len(cm.Cursors) != 1 || cm.Cursors[0].Row != 2 {
+		t.Errorf("ClearSecondary kept %v, want the last added cursor", cm.Cursors)
+	}
+}
+
+func TestMultiCursorUndo(t *testing.T) {
+	cm := NewCursorManager()
+	b := NewBuffer()
+	b.TrackCursors(cm)
+	b.Load("one\ntwo")
+	cm.Cursors[0] = Cursor{Row: 0, Col: 3}
+	cm.AddCursor(Cursor{Row: 1, Col: 3})
+	nodes := b.History.Len()
+
+	for _, r := range "hello x" {
+		InsertAtCursors(b, cm, string(r))
+	}
+	if b.String() != "onehello x\ntwohello x" {
+		t.Fatalf("typed %q", b.String())
+	}
+	if steps := b.History.Len() - nodes; steps != 2 {
+		t.Fatalf("typing made %d undo steps, want 2 as with one cursor", steps)
+	}
+	for i := 0; i < 7; i++ {
+		DeleteAtCursors(b, cm)
+	}
+	if steps := b.History.Len() - nodes; steps != 4 {
+		t.Fatalf("typing and deleting made %d undo steps, want 4", steps)
+	}
+	b.Undo()
+	if b.String() != "onehello \ntwohello " {
+		t.Fatalf("undo: %q", b.String())
+	}
+	for b.Undo() {
+	}
+	if b.String() != "one\ntwo" {
+		t.Fatalf("undo all: %q", b.String())
+	}
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// pieceSource identifies which backing buffer a piece points into.
+type pieceSource uint8
+
+const (
+	sourceOriginal pieceSource = iota // the text the table was created with
+	sourceAdd                         // text appended by later inserts
+)
+
+// piece is a span of one of the backing buffers.
+type piece struct {
+	source pieceSource
+	start  int
+	length int
+}
+
+// PieceTable stores text as a list of pieces pointing into an immutable
+// original buffer and an append-only add buffer. Inserts and deletes only
+// split or drop the pieces around the edit, so their cost does not depend on
+// the size of the text.
+type PieceTable struct {
+	original string
+	add      []byte
+	pieces   []piece
+	length   int
+
+	cache      string // materialized text, valid while cacheValid is set
+	cacheValid bool
+
+	starts      []int // offset at which each piece starts, valid while startsValid is set
+	startsValid bool
+}
+
+func NewPieceTable(content string) *PieceTable {
+	pt := &PieceTable{
+		original: content,
+		length:   len(content),
+	}
+	if content != "" {
+		pt.pieces = []piece{{source: sourceOriginal, start: 0, length: len(content)}}
+	}
+	return pt
+}
+
+// Len returns the length of the text in bytes.
+func (pt *PieceTable) Len() int {
+	return pt.length
+}
+
+// locate returns the index of the piece containing offset and the offset
+// within that piece. An offset on a piece boundary maps to the start of the
+// following piece; the end of the text maps to len(pt.pieces).
+func (pt *PieceTable) locate(offset int) (int, int) {
+	if !pt.startsValid {
+		pt.starts = pt.starts[:0]
+		pos := 0
+		for _, p := range pt.pieces {
+			pt.starts = append(pt.starts, pos)
+			pos += p.length
+		}
+		pt.startsValid = true
+	}
+	if offset >= pt.length {
+		return len(pt.pieces), 0
+	}
+	i := sort.SearchInts(pt.starts, offset+1) - 1
+	return i, offset - pt.starts[i]
+}
+
+// changed drops everything derived from the piece list.
+func (pt *PieceTable) changed() {
+	pt.cacheValid = false
+	pt.startsValid = false
+}
+
+// split makes sure a piece starts exactly at offset and returns its index.
+func (pt *PieceTable) split(offset int) int {
+	i, off := pt.locate(offset)
+	if off == 0 {
+		return i
+	}
+
+	p := pt.pieces[i]
+	pt.pieces = append(pt.pieces, piece{})
+	copy(pt.pieces[i+2:], pt.pieces[i+1:])
+	pt.pieces[i] = piece{source: p.source, start: p.start, length: off}
+	pt.pieces[i+1] = piece{source: p.source, start: p.start + off, length: p.length - off}
+	pt.changed()
+	return i + 1
+}
+
+// Insert inserts text at the given byte offset.
+func (pt *PieceTable) Insert(offset int, text string) {
+	if text == "" {
+		return
+	}
+	offset = pt.clamp(offset)
+	i, off := pt.locate(offset)
+
+	start := len(pt.add)
+	pt.add = append(pt.add, text...)
+
+	// Typing usually continues right after the previous insert, in which
+	// case the last piece can simply grow.
+	if prev := i - 1; off == 0 && prev >= 0 &&
+		pt.pieces[prev].source == sourceAdd && pt.pieces[prev].start+pt.pieces[prev].length == start {
+		pt.pieces[prev].length += len(text)
+	} else {
+		i = pt.split(offset)
+		pt.pieces = slices.Insert(pt.pieces, i, piece{source: sourceAdd, start: start, length: len(text)})
+	}
+	pt.length += len(text)
+	pt.changed()
+}
+
+// Delete removes the bytes in [start, end).
+func (pt *PieceTable) Delete(start, end int) {
+	start, end = pt.clamp(start), pt.clamp(end)
+	if start >= end {
+		return
+	}
+
+	i := pt.split(start)
+	j := pt.split(end)
+	pt.pieces = append(pt.pieces[:i], pt.pieces[j:]...)
+	pt.length -= end - start
+	pt.changed()
+}
+
+// spans returns the pieces holding the text in [start, end), which
+// insertSpans can put back later without copying the bytes again.
+func (pt *PieceTable) spans(start, end int) []piece {
+	start, end = pt.clamp(start), pt.clamp(end)
+	if start >= end {
+		return nil
+	}
+
+	var spans []piece
+	i, off := pt.locate(start)
+	for pos := start; pos < end; i, off = i+1, 0 {
+		p := pt.pieces[i]
+		to := min(p.length, off+end-pos)
+		spans = append(spans, piece{source: p.source, start: p.start + off, length: to - off})
+		pos += to - off
+	}
+	return spans
+}
+
+// insertSpans inserts the text of spans, taken from this table by spans, at
+// the given byte offset.
+func (pt *PieceTable) insertSpans(offset int, spans []piece) {
+	if len(spans) == 0 {
+		return
+	}
+	i := pt.split(pt.clamp(offset))
+	pt.pieces = slices.Insert(pt.pieces, i, spans...)
+	for _, p := range spans {
+		pt.length += p.length
+	}
+	pt.changed()
+}
+
+// Slice returns the text in [start, end).
+func (pt *PieceTable) Slice(start, end int) string {
+	start, end = pt.clamp(start), pt.clamp(end)
+	if start >= end {
+		return ""
+	}
+
+	if pt.cacheValid {
+		return pt.cache[start:end]
+	}
+
+	var sb strings.Builder
+	sb.Grow(end - start)
+	i, off := pt.locate(start)
+	for pos := start; pos < end; i, off = i+1, 0 {
+		p := pt.pieces[i]
+		to := min(p.length, off+end-pos)
+		pt.write(&sb, p, off, to)
+		pos += to - off
+	}
+	return sb.String()
+}
+
+// String returns the whole text. The result is cached until the next edit.
+func (pt *PieceTable) String() string {
+	if !pt.cacheValid {
+		var sb strings.Builder
+		sb.Grow(pt.length)
+		for _, p := range pt.pieces {
+			pt.write(&sb, p, 0, p.length)
+		}
+		pt.cache = sb.String()
+		pt.cacheValid = true
+	}
+	return pt.cache
+}
+
+func (pt *PieceTable) write(sb *strings.Builder, p piece, from, to int) {
+	if p.source == sourceOriginal {
+		sb.WriteString(pt.original[p.start+from : p.start+to])
+	} else {
+		sb.Write(pt.add[p.start+from : p.start+to])
+	}
+}
+
+// freeze returns a copy of the table that later edits to pt don't affect.
+// The backing buffers are shared: the original never changes and the add
+// buffer is only ever appended to, past the end of the copy's view of it.
+func (pt *PieceTable) freeze() *PieceTable {
+	return &PieceTable{
+		original:   pt.original,
+		add:        pt.add[:len(pt.add):len(pt.add)],
+		pieces:     slices.Clone(pt.pieces),
+		length:     pt.length,
+		cache:      pt.cache,
+		cacheValid: pt.cacheValid,
+	}
+}
+
+func (pt *PieceTable) clamp(offset int) int {
+	return max(0, min(offset, pt.length))
+}
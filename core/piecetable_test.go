@@ -0,0 +1,133 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPieceTable(t *testing.T) {
+	type op struct {
+		insert     string // Inserted at start when set, otherwise start:end is deleted
+		start, end int
+	}
+	tests := []struct {
+		name    string
+		content string
+		ops     []op
+		want    string
+	}{
+		{"empty", "", nil, ""},
+		{"insert into empty", "", []op{{insert: "abc"}}, "abc"},
+		{"insert at end", "abc", []op{{insert: "def", start: 3}}, "abcdef"},
+		{"insert in middle", "ad", []op{{insert: "bc", start: 1}}, "abcd"},
+		{"insert twice at same offset", "ac", []op{{insert: "x", start: 1}, {insert: "b", start: 1}}, "abxc"},
+		{"delete all", "abc", []op{{start: 0, end: 3}}, ""},
+		{"delete across pieces", "abef", []op{{insert: "cd", start: 2}, {start: 1, end: 5}}, "af"},
+		{"delete inside inserted piece", "af", []op{{insert: "bcde", start: 1}, {start: 2, end: 4}}, "abef"},
+		{"empty delete", "abc", []op{{start: 1, end: 1}}, "abc"},
+		{"out of range offsets clamp", "abc", []op{{insert: "!", start: 10}, {start: -5, end: 1}}, "bc!"},
+		{"multibyte", "héllo", []op{{insert: "€", start: 3}, {start: 0, end: 1}}, "é€llo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := NewPieceTable(tt.content)
+			for _, o := range tt.ops {
+				if o.insert != "" {
+					pt.Insert(o.start, o.insert)
+				} else {
+					pt.Delete(o.start, o.end)
+				}
+			}
+			if got := pt.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if pt.Len() != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", pt.Len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestPieceTableSlice(t *testing.T) {
+	pt := NewPieceTable("hello world")
+	pt.Insert(5, ",")
+	pt.Insert(12, "!")
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{0, 12, "hello, world"},
+		{0, 13, "hello, world!"},
+		{3, 8, "lo, w"},
+		{5, 6, ","},
+		{6, 6, ""},
+		{-1, 2, "he"},
+		{11, 100, "d!"},
+	}
+	for _, tt := range tests {
+		if got := pt.Slice(tt.start, tt.end); got != tt.want {
+			t.Errorf("Slice(%d, %d) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+// TestPieceTableRandom checks random edits against the same edits made to a
+// plain string.
+func TestPieceTableRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	want := "hello\nworld"
+	pt := NewPieceTable(want)
+	for i := 0; i < 5000; i++ {
+		if rng.Intn(2) == 0 || want == "" {
+			offset := rng.Intn(len(want) + 1)
+			text := []string{"a", "xy\nz", "é"}[rng.Intn(3)]
+			pt.Insert(offset, text)
+			want = want[:offset] + text + want[offset:]
+		} else {
+			start := rng.Intn(len(want))
+			end := start + rng.Intn(len(want)-start+1)
+			pt.Delete(start, end)
+			want = want[:start] + want[end:]
+		}
+		// Slice before String, which caches the whole text
+		start := rng.Intn(len(want) + 1)
+		end := start + rng.Intn(len(want)-start+1)
+		if got := pt.Slice(start, end); got != want[start:end] {
+			t.Fatalf("edit %d: Slice(%d, %d) = %q, want %q", i, start, end, got, want[start:end])
+		}
+		if pt.String() != want || pt.Len() != len(want) {
+			t.Fatalf("edit %d: got %q, want %q", i, pt.String(), want)
+		}
+	}
+}
+
+func TestPieceTableSpans(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int // Cut out and put back at to
+		to         int
+		want       string
+	}{
+		{"inside one piece", 1, 3, 1, "hello, world"},
+		{"across pieces", 3, 8, 3, "hello, world"},
+		{"moved", 0, 7, 5, "worldhello, "},
+		{"everything", 0, 12, 0, "hello, world"},
+		{"nothing", 4, 4, 0, "hello, world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := NewPieceTable("hello world")
+			pt.Insert(5, ",")
+			added := len(pt.add)
+			spans := pt.spans(tt.start, tt.end)
+			pt.Delete(tt.start, tt.end)
+			pt.insertSpans(tt.to, spans)
+			if got := pt.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if pt.Len() != len(tt.want) || len(pt.add) != added {
+				t.Errorf("Len() = %d with %d bytes added, want %d with %d", pt.Len(), len(pt.add), len(tt.want), added)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrReadOnly is returned when saving a read-only document.
+var ErrReadOnly = errors.New("buffer is read-only")
+
+// SetReadOnly makes the buffer refuse every change to its text: edits, undo
+// and redo, and line ending conversion. Cursors, marks, bookmarks and search
+// keep working.
+func (b *Buffer) SetReadOnly(readOnly bool) {
+	b.readOnly = readOnly
+}
+
+// ReadOnly reports whether the buffer refuses changes.
+func (b *Buffer) ReadOnly() bool {
+	return b.readOnly
+}
+
+// writable reports whether the file at path can be written to, without
+// changing it.
+func writable(path string) bool {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyRefusesEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(b *Buffer, cm *CursorManager)
+	}{
+		{"insert", func(b *Buffer, cm *CursorManager) { b.Insert(0, "x") }},
+		{"delete", func(b *Buffer, cm *CursorManager) { b.Delete(Range{Start: 0, End: 2}) }},
+		{"set content", func(b *Buffer, cm *CursorManager) { b.SetContent("x") }},
+		{"line endings", func(b *Buffer, cm *CursorManager) { b.SetLineEnding(LineEndingCRLF) }},
+		{"typing", func(b *Buffer, cm *CursorManager) { InsertAtCursors(b, cm, "y") }},
+		{"backspace", func(b *Buffer, cm *CursorManager) { DeleteAtCursors(b, cm) }},
+		{"delete word", func(b *Buffer, cm *CursorManager) { DeleteWordLeft(b, cm, false) }},
+		{"transaction", func(b *Buffer, cm *CursorManager) {
+			b.Transact(func() error { b.Insert(0, "x"); return nil })
+		}},
+		{"undo", func(b *Buffer, cm *CursorManager) { b.Undo() }},
+		{"earlier", func(b *Buffer, cm *CursorManager) { b.Earlier(time.Hour) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load("one\ntwo")
+			b.Insert(0, "0")
+			b.SetReadOnly(true)
+			cm := &CursorManager{Cursors: []Cursor{{Row: 0, Col: 2}}}
+			b.TrackCursors(cm)
+			version, nodes := b.Version(), b.History.Len()
+			events := 0
+			b.Subscribe(func(ChangeEvent) { events++ })
+
+			tt.edit(b, cm)
+			if b.TextWithLineEndings() != "0one\ntwo" || b.Version() != version || events != 0 {
+				t.Errorf("read-only buffer changed to %q", b.TextWithLineEndings())
+			}
+			if b.History.Len() != nodes || b.History.Current == b.History.Root {
+				t.Error("read-only buffer changed its undo history")
+			}
+		})
+	}
+}
+
+func TestReadOnlyKeepsNavigation(t *testing.T) {
+	b := NewBuffer()
+	b.Load("one two\nthree")
+	b.SetReadOnly(true)
+	cm := &CursorManager{Cursors: []Cursor{{Row: 0, Col: 0}}}
+	b.TrackCursors(cm)
+
+	MoveWordRight(b, cm, false, true)
+	MoveRight(b, cm, true)
+	if got := cm.GetSelectedText(0, b); got != "one " {
+		t.Errorf("selected %q, want \"one \"", got)
+	}
+	if r, ok := b.Find("three", 0); !ok || r != (Range{Start: 8, End: 13}) {
+		t.Errorf("Find(\"three\") = %v, %v", r, ok)
+	}
+	b.ToggleBookmark(1)
+	if !b.HasBookmark(1) {
+		t.Error("bookmark refused")
+	}
+
+	b.SetReadOnly(false)
+	b.Insert(0, "x")
+	if b.String() != "xone two\nthree" {
+		t.Errorf("editable again: %q", b.String())
+	}
+}
+
+func TestReadOnlyDocument(t *testing.T) {
+	paths := writeFiles(t, "one", "two")
+	w := NewWorkspace()
+	w.ReadOnly = true
+	d, _ := w.Open(paths[0], nil)
+	if !d.Buffer.ReadOnly() {
+		t.Fatal("document opened editable with Workspace.ReadOnly set")
+	}
+	if err := d.Save(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Save() = %v, want ErrReadOnly", err)
+	}
+
+	if err := os.Chmod(paths[1], 0444); err != nil {
+		t.Fatal(err)
+	}
+	w2 := NewWorkspace()
+	d2, _ := w2.Open(paths[1], nil)
+	if os.Getuid() != 0 && !d2.Buffer.ReadOnly() { // root can write anyway
+		t.Error("unwritable file opened editable")
+	}
+	if d, _ := w2.Open(paths[0], nil); d.Buffer.ReadOnly() {
+		t.Error("writable file opened read-only")
+	}
+}
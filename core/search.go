@@ -0,0 +1,65 @@
+package core
+
+import "strings"
+
+// Find returns the first match of query at or after the byte offset from,
+// wrapping around to the start of the text. ok is false if query does not
+// occur at all.
+func (b *Buffer) Find(query string, from int) (match Range, ok bool) {
+	return find(b.String(), query, from)
+}
+
+// FindPrev returns the last match of query that starts before the byte
+// offset from, wrapping around to the end of the text.
+func (b *Buffer) FindPrev(query string, from int) (match Range, ok bool) {
+	return findPrev(b.String(), query, from)
+}
+
+// FindAll returns every match of query in order. Matches don't overlap.
+func (b *Buffer) FindAll(query string) []Range {
+	return findAll(b.String(), query)
+}
+
+func find(text, query string, from int) (Range, bool) {
+	if query == "" {
+		return Range{}, false
+	}
+	from = max(0, min(from, len(text)))
+	if i := strings.Index(text[from:], query); i >= 0 {
+		return Range{Start: from + i, End: from + i + len(query)}, true
+	}
+	if i := strings.Index(text, query); i >= 0 {
+		return Range{Start: i, End: i + len(query)}, true
+	}
+	return Range{}, false
+}
+
+func findPrev(text, query string, from int) (Range, bool) {
+	if query == "" {
+		return Range{}, false
+	}
+	from = max(0, min(from, len(text)))
+	if i := strings.LastIndex(text[:min(from+len(query)-1, len(text))], query); i >= 0 {
+		return Range{Start: i, End: i + len(query)}, true
+	}
+	if i := strings.LastIndex(text, query); i >= 0 {
+		return Range{Start: i, End: i + len(query)}, true
+	}
+	return Range{}, false
+}
+
+func findAll(text, query string) []Range {
+	if query == "" {
+		return nil
+	}
+	var matches []Range
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], query)
+		if i < 0 {
+			return matches
+		}
+		start := offset + i
+		matches = append(matches, Range{Start: start, End: start + len(query)})
+		offset = start + len(query)
+	}
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Session is what the editor remembers about files between runs.
+type Session struct {
+	Files []SessionFile
+}
+
+// SessionFile is the remembered state of one file.
+type SessionFile struct {
+	Path      string // Absolute path
+	Bookmarks []int  // Bookmarked lines
+}
+
+// sessionFilePath returns where the session is kept, next to the undo
+// histories in the user's cache directory.
+func sessionFilePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "go-text-editor", "session.json"), nil
+}
+
+// LoadSession reads the saved session. A missing session file gives an
+// empty session and no error.
+func LoadSession() (*Session, error) {
+	path, err := sessionFilePath()
+	if err != nil {
+		return &Session{}, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Session{}, nil
+	} else if err != nil {
+		return &Session{}, err
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &Session{}, fmt.Errorf("corrupt session %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes the session, replacing the saved one.
+func (s *Session) Save() error {
+	path, err := sessionFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// File returns the remembered state of filePath, or nil if there is none.
+func (s *Session) File(filePath string) *SessionFile {
+	abs, _ := filepath.Abs(filePath)
+	for i := range s.Files {
+		if s.Files[i].Path == abs {
+			return &s.Files[i]
+		}
+	}
+	return nil
+}
+
+// SetBookmarks remembers the bookmarked lines of filePath. Files without
+// bookmarks are forgotten.
+func (s *Session) SetBookmarks(filePath string, rows []int) {
+	abs, _ := filepath.Abs(filePath)
+	s.Files = slices.DeleteFunc(s.Files, func(f SessionFile) bool { return f.Path == abs })
+	if len(rows) > 0 {
+		s.Files = append(s.Files, SessionFile{Path: abs, Bookmarks: rows})
+	}
+}
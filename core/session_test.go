@@ -0,0 +1,50 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSessionSetBookmarks(t *testing.T) {
+	s := &Session{}
+	s.SetBookmarks("a.txt", []int{1, 2})
+	s.SetBookmarks("b.txt", []int{3})
+	s.SetBookmarks("a.txt", []int{5})
+	s.SetBookmarks("b.txt", nil)
+
+	abs, _ := filepath.Abs("a.txt")
+	if len(s.Files) != 1 || s.Files[0].Path != abs || !slices.Equal(s.Files[0].Bookmarks, []int{5}) {
+		t.Fatalf("session files %v, want only %s at [5]", s.Files, abs)
+	}
+	if s.File("a.txt") != &s.Files[0] || s.File("b.txt") != nil {
+		t.Error("File doesn't find the remembered files")
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	useTempCache(t)
+	s, err := LoadSession()
+	if err != nil || len(s.Files) != 0 {
+		t.Fatalf("LoadSession without a saved session: %v, %v", s.Files, err)
+	}
+	s.SetBookmarks("a.txt", []int{1, 4})
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, err := LoadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := s2.File("a.txt"); f == nil || !slices.Equal(f.Bookmarks, []int{1, 4}) {
+		t.Errorf("loaded %v, want [1 4] for a.txt", s2.Files)
+	}
+
+	path, _ := sessionFilePath()
+	os.WriteFile(path, []byte("{not json"), 0644)
+	if s3, err := LoadSession(); err == nil || s3 == nil || len(s3.Files) != 0 {
+		t.Errorf("corrupt session loaded as %v, %v", s3, err)
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// Snapshot is a read-only copy of a Buffer's text at one version. Taking one
+// only copies the list of pieces, and it stays valid while the buffer keeps
+// changing, so it can be handed to a background goroutine. Any number of
+// goroutines may read the same snapshot at once.
+//
+// The text and its lines are worked out on first use, by whichever reader
+// gets there first, rather than on the goroutine that took the snapshot.
+type Snapshot struct {
+	version int
+	table   *PieceTable
+
+	once  sync.Once
+	text  string
+	lines *lineIndex
+}
+
+// Snapshot returns the current text of the buffer. Comparing its Version
+// with the buffer's tells whether results computed from it are out of date.
+// Like every other Buffer method it must be called on the goroutine that
+// edits the buffer; only the snapshot itself may be passed on.
+func (b *Buffer) Snapshot() *Snapshot {
+	if b.snapshot == nil {
+		b.snapshot = &Snapshot{version: b.version, table: b.table.freeze()}
+	}
+	return b.snapshot
+}
+
+// load materializes the text and line index.
+func (s *Snapshot) load() {
+	s.once.Do(func() {
+		s.text = s.table.String()
+		s.lines = newLineIndex(s.text)
+		s.table = nil
+	})
+}
+
+// Version returns the version of the buffer the snapshot was taken at.
+func (s *Snapshot) Version() int {
+	return s.version
+}
+
+// String returns the full text.
+func (s *Snapshot) String() string {
+	s.load()
+	return s.text
+}
+
+// Len returns the length of the text in bytes.
+func (s *Snapshot) Len() int {
+	return len(s.String())
+}
+
+// Slice returns the text covered by r.
+func (s *Snapshot) Slice(r Range) string {
+	text := s.String()
+	start, end := max(0, min(r.Start, len(text))), max(0, min(r.End, len(text)))
+	if start >= end {
+		return ""
+	}
+	return text[start:end]
+}
+
+// LineCount returns the number of lines.
+func (s *Snapshot) LineCount() int {
+	s.load()
+	return s.lines.count()
+}
+
+// Line returns the text of line i without its newline.
+func (s *Snapshot) Line(i int) string {
+	s.load()
+	return s.Slice(s.lines.lineRange(i, len(s.text)))
+}
+
+// OffsetToPos converts a byte offset into a row and rune column.
+func (s *Snapshot) OffsetToPos(offset int) (int, int) {
+	s.load()
+	offset = max(0, min(offset, len(s.text)))
+	row := s.lines.lineAt(offset)
+	return row, utf8.RuneCountInString(s.text[s.lines.start(row):offset])
+}
+
+// Find is Buffer.Find on the snapshot.
+func (s *Snapshot) Find(query string, from int) (match Range, ok bool) {
+	return find(s.String(), query, from)
+}
+
+// FindPrev is Buffer.FindPrev on the snapshot.
+func (s *Snapshot) FindPrev(query string, from int) (match Range, ok bool) {
+	return findPrev(s.String(), query, from)
+}
+
+// FindAll is Buffer.FindAll on the snapshot.
+func (s *Snapshot) FindAll(query string) []Range {
+	return findAll(s.String(), query)
+}
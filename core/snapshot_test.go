@@ -0,0 +1,94 @@
+package core
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	b := NewBuffer()
+	b.Load("hello\nworld")
+	s := b.Snapshot()
+	if b.Snapshot() != s {
+		t.Error("unchanged buffer made a new snapshot")
+	}
+	b.Insert(5, " there")
+	b.Delete(Range{Start: 0, End: 1})
+
+	if s.Version() == b.Version() {
+		t.Error("snapshot has the version of the edited buffer")
+	}
+	if s.String() != "hello\nworld" || s.Len() != 11 || s.Slice(Range{Start: 2, End: 4}) != "ll" {
+		t.Errorf("snapshot text changed to %q", s.String())
+	}
+	if s.LineCount() != 2 || s.Line(1) != "world" {
+		t.Errorf("snapshot has %d lines, the second %q", s.LineCount(), s.Line(1))
+	}
+	if row, col := s.OffsetToPos(8); row != 1 || col != 2 {
+		t.Errorf("OffsetToPos(8) = %d, %d, want 1, 2", row, col)
+	}
+	if got := s.FindAll("o"); !slices.Equal(got, []Range{{Start: 4, End: 5}, {Start: 7, End: 8}}) {
+		t.Errorf("FindAll(\"o\") = %v", got)
+	}
+	if now := b.Snapshot(); now == s || now.Version() != b.Version() || now.String() != "ello there\nworld" {
+		t.Errorf("snapshot after editing: %q at version %d", now.String(), now.Version())
+	}
+}
+
+func TestSnapshotInTransaction(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(b *Buffer)
+		want string
+	}{
+		{"commit", (*Buffer).Commit, "xabc"},
+		{"rollback", (*Buffer).Rollback, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load("abc")
+			b.Begin()
+			b.Insert(0, "x")
+			inside := b.Snapshot()
+			tt.end(b)
+
+			s := b.Snapshot()
+			if s.String() != tt.want || s.Version() != b.Version() {
+				t.Errorf("snapshot after the transaction: %q at version %d, want %q at %d", s.String(), s.Version(), tt.want, b.Version())
+			}
+			if inside.String() != "xabc" {
+				t.Errorf("snapshot taken inside the transaction changed to %q", inside.String())
+			}
+		})
+	}
+}
+
+// TestSnapshotConcurrent reads snapshots on other goroutines while the
+// buffer keeps changing. Run it with -race.
+func TestSnapshotConcurrent(t *testing.T) {
+	b := NewBuffer()
+	b.Load(strings.Repeat("abc\n", 100))
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		s := b.Snapshot()
+		want := b.String()
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if s.String() != want || len(s.FindAll("abc")) != strings.Count(want, "abc") || s.LineCount() != strings.Count(want, "\n")+1 {
+					t.Errorf("snapshot %d doesn't match the text it was taken of", s.Version())
+				}
+			}()
+		}
+		b.Insert(i*3%b.Len(), "xyz")
+		if i%7 == 0 {
+			b.Delete(Range{Start: 0, End: 5})
+			b.Undo()
+		}
+	}
+	wg.Wait()
+}
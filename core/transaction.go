@@ -0,0 +1,130 @@
+package core
+
+// transaction collects the edits made between Begin and Commit, so they
+// reach the undo history and the change listeners as one change.
+type transaction struct {
+	edits      []Edit
+	savepoints []savepoint // One per open Begin, innermost last
+	start      *UndoNode   // History state when the transaction began
+	redo       int         // Redo branch of start before the transaction
+
+	batch     bool // Started by beginBatch, so it may join the previous undo step
+	startOpen bool // start could still take more edits before the transaction
+
+	// Span touched so far in current offsets, and how much longer the text
+	// got, for the combined change event.
+	changed bool
+	lo, hi  int
+	delta   int
+}
+
+// savepoint is where a nested Begin started, for Rollback to return to.
+type savepoint struct {
+	edits   int
+	cursors CursorState
+}
+
+// Begin starts a transaction: every edit until the matching Commit becomes a
+// single undo step and a single change event, and Rollback can take them
+// all back. Transactions may be nested; an inner Rollback only takes back
+// the edits made since its own Begin. Undo, redo and history navigation
+// must not be used until the outermost transaction is closed.
+func (b *Buffer) Begin() {
+	if b.txn == nil {
+		b.beginUndoGroup()
+		b.txn = &transaction{start: b.History.Current, redo: b.History.Current.redo}
+	}
+	b.txn.savepoints = append(b.txn.savepoints, savepoint{edits: len(b.txn.edits), cursors: b.cursorState()})
+}
+
+// beginBatch is Begin for one keystroke that edits at several cursors. At
+// Commit its edits join the previous undo step if each cursor's edit would
+// have joined it on its own, see joinBatch.
+func (b *Buffer) beginBatch() {
+	open := b.History.open() != nil // Before Begin seals it
+	b.Begin()
+	if len(b.txn.savepoints) == 1 {
+		b.txn.batch, b.txn.startOpen = true, open
+	}
+}
+
+// Commit closes the innermost transaction. Closing the outermost one tells
+// the listeners about everything that changed in one event.
+func (b *Buffer) Commit() {
+	txn := b.txn
+	if txn == nil {
+		return
+	}
+	txn.savepoints = txn.savepoints[:len(txn.savepoints)-1]
+	if len(txn.savepoints) > 0 {
+		return
+	}
+
+	b.txn = nil
+	b.endUndoGroup()
+	if txn.batch {
+		b.History.joinBatch(txn.start, txn.startOpen)
+	}
+	if txn.changed {
+		b.snapshot = nil // Taken inside the transaction, before the version changes
+		b.notify(Range{Start: txn.lo, End: txn.hi - txn.delta}, b.Slice(Range{Start: txn.lo, End: txn.hi}))
+	}
+}
+
+// Rollback undoes every edit made since the innermost Begin and closes that
+// transaction, restoring the cursors as they were. Rolling back the
+// outermost transaction leaves no trace in the undo history and sends no
+// change event.
+func (b *Buffer) Rollback() {
+	txn := b.txn
+	if txn == nil {
+		return
+	}
+	sp := txn.savepoints[len(txn.savepoints)-1]
+	txn.savepoints = txn.savepoints[:len(txn.savepoints)-1]
+
+	for i := len(txn.edits) - 1; i >= sp.edits; i-- {
+		b.apply(txn.edits[i].invert())
+	}
+	txn.edits = txn.edits[:sp.edits]
+	b.History.truncate(txn.start, len(txn.edits))
+	b.restoreCursors(sp.cursors)
+
+	if len(txn.savepoints) == 0 {
+		b.txn = nil
+		b.endUndoGroup()
+		txn.start.redo = txn.redo
+	}
+}
+
+// Transact runs fn inside a transaction, committing it if fn succeeds and
+// rolling it back if fn returns an error.
+func (b *Buffer) Transact(fn func() error) error {
+	b.Begin()
+	if err := fn(); err != nil {
+		b.Rollback()
+		return err
+	}
+	b.Commit()
+	return nil
+}
+
+// touch widens the span of the transaction to cover deleted bytes at pos
+// being replaced by inserted bytes.
+func (txn *transaction) touch(pos, deleted, inserted int) {
+	if !txn.changed {
+		txn.changed = true
+		txn.lo, txn.hi = pos, pos+inserted
+		txn.delta = inserted - deleted
+		return
+	}
+	switch {
+	case txn.hi >= pos+deleted:
+		txn.hi += inserted - deleted
+	case txn.hi > pos:
+		txn.hi = pos + inserted // Ended inside the deleted text
+	}
+	txn.lo = min(txn.lo, pos)
+	txn.hi = max(txn.hi, pos+inserted)
+	txn.delta += inserted - deleted
+}
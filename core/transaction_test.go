@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactions(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(b *Buffer)
+		want   string
+		events []ChangeEvent // Without versions
+		steps  int
+	}{
+		{"commit", func(b *Buffer) {
+			b.Begin()
+			b.Delete(Range{Start: 0, End: 5})
+			b.Insert(0, "bye")
+			b.Insert(b.Len(), "!")
+			b.Commit()
+		}, "bye world!", []ChangeEvent{{Range: Range{Start: 0, End: 11}, Text: "bye world!"}}, 1},
+		{"rollback", func(b *Buffer) {
+			b.Begin()
+			b.Insert(0, "xx")
+			b.Delete(Range{Start: 5, End: 8})
+			b.Rollback()
+		}, "hello world", nil, 0},
+		{"nested commit", func(b *Buffer) {
+			b.Begin()
+			b.Insert(6, "big ")
+			b.Begin()
+			b.Delete(Range{Start: 0, End: 6})
+			b.Commit()
+			b.Commit()
+		}, "big world", []ChangeEvent{{Range: Range{Start: 0, End: 6}, Text: "big "}}, 1},
+		{"nested rollback", func(b *Buffer) {
+			b.Begin()
+			b.Insert(0, "xx")
+			b.Begin()
+			b.Insert(0, "yy")
+			b.Rollback()
+			b.Commit()
+		}, "xxhello world", []ChangeEvent{{Range: Range{Start: 0, End: 0}, Text: "xx"}}, 1},
+		{"failed transact", func(b *Buffer) {
+			b.Transact(func() error {
+				b.Insert(0, "xx")
+				return errors.New("failed")
+			})
+		}, "hello world", nil, 0},
+		{"transact", func(b *Buffer) {
+			b.Transact(func() error {
+				b.Insert(5, ",")
+				b.Insert(0, "oh, ")
+				return nil
+			})
+		}, "oh, hello, world", []ChangeEvent{{Range: Range{Start: 0, End: 5}, Text: "oh, hello,"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer()
+			b.Load("hello world")
+			var events []ChangeEvent
+			b.Subscribe(func(e ChangeEvent) {
+				e.Version = 0
+				events = append(events, e)
+			})
+			nodes := b.History.Len()
+
+			tt.edit(b)
+			if got := b.String(); got != tt.want {
+				t.Fatalf("text %q, want %q", got, tt.want)
+			}
+			if len(events) != len(tt.events) {
+				t.Fatalf("events %v, want %v", events, tt.events)
+			}
+			for i := range events {
+				if events[i] != tt.events[i] {
+					t.Errorf("event %d: %v, want %v", i, events[i], tt.events[i])
+				}
+			}
+			if steps := b.History.Len() - nodes; steps != tt.steps {
+				t.Fatalf("made %d undo steps, want %d", steps, tt.steps)
+			}
+			if tt.steps > 0 {
+				if b.Undo(); b.String() != "hello world" {
+					t.Fatalf("undo: %q", b.String())
+				}
+				if b.Redo(); b.String() != tt.want {
+					t.Fatalf("redo: %q", b.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const undoFileVersion = 1
+
+var errStaleHistory = errors.New("undo history does not match the file")
+
+// savedHistory is the on-disk form of an undo tree. Nodes are stored oldest
+// first, so every node's parent comes before it.
+type savedHistory struct {
+	Version     int
+	Path        string
+	ContentHash [sha256.Size]byte // hash of the text in the Current state, the one in the file
+	Nodes       []savedNode
+	Current     int
+}
+
+// undoFile wraps the encoded history with a checksum, so a damaged file is
+// noticed even when it still decodes.
+type undoFile struct {
+	Checksum [sha256.Size]byte
+	Payload  []byte
+}
+
+type savedNode struct {
+	Parent        int // index into Nodes, -1 for the root
+	Seq           int
+	Time          time.Time
+	Edits         []Edit
+	CursorsBefore CursorState
+	CursorsAfter  CursorState
+	Redo          int
+}
+
+// undoFilePath returns where the undo history of filePath is kept: a file in
+// the user's cache directory named after the hash of the absolute path.
+func undoFilePath(filePath string) (string, error) {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(cacheDir, "go-text-editor", "undo", hex.EncodeToString(sum[:])+".undo"), nil
+}
+
+// SaveHistory writes the undo history of the buffer next to the other cached
+// histories, keyed by filePath and the text of state at, which has to be the
+// text in the file. Reopening the file starts at that state, with the edits
+// made since it was saved a Redo away. Nothing is written if the state was
+// dropped from the history to stay under the memory limit.
+func SaveHistory(filePath string, b *Buffer, at *UndoNode) error {
+	b.SealUndo()
+	if at.dropped {
+		return nil
+	}
+	path, err := undoFilePath(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to save undo history: %w", err)
+	}
+	abs, _ := filepath.Abs(filePath)
+
+	up, down := b.History.path(b.History.Current, at)
+	saved := savedHistory{
+		Version:     undoFileVersion,
+		Path:        abs,
+		ContentHash: sha256.Sum256([]byte(textAt(b.String(), up, down))),
+	}
+	index := make(map[*UndoNode]int, b.History.Len())
+	for i, node := range b.History.Nodes() {
+		index[node] = i
+		parent := -1
+		if node.Parent != nil {
+			parent = index[node.Parent]
+		}
+		saved.Nodes = append(saved.Nodes, savedNode{
+			Parent:        parent,
+			Seq:           node.Seq,
+			Time:          node.Time,
+			Edits:         node.Entry.Edits,
+			CursorsBefore: node.Entry.CursorsBefore,
+			CursorsAfter:  node.Entry.CursorsAfter,
+			Redo:          node.redo,
+		})
+	}
+	saved.Current = index[at]
+	for _, node := range up {
+		saved.Nodes[index[node.Parent]].Redo = childIndex(node) // Redo leads back to the unsaved edits
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to save undo history: %w", err)
+	}
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(saved); err != nil {
+		return fmt.Errorf("failed to save undo history: %w", err)
+	}
+
+	var out bytes.Buffer
+	file := undoFile{Checksum: sha256.Sum256(payload.Bytes()), Payload: payload.Bytes()}
+	if err := gob.NewEncoder(&out).Encode(file); err != nil {
+		return fmt.Errorf("failed to save undo history: %w", err)
+	}
+	if err := writeFileAtomic(path, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to save undo history: %w", err)
+	}
+	return nil
+}
+
+// textAt returns the text reached from content by undoing the states in up
+// and redoing those in down, as returned by UndoTree.path.
+func textAt(content string, up, down []*UndoNode) string {
+	table := NewPieceTable(content)
+	apply := func(edit Edit) {
+		table.Delete(edit.Pos, edit.Pos+len(edit.Deleted))
+		table.Insert(edit.Pos, edit.Inserted)
+	}
+	for _, node := range up {
+		for i := len(node.Entry.Edits) - 1; i >= 0; i-- {
+			apply(node.Entry.Edits[i].invert())
+		}
+	}
+	for _, node := range down {
+		for _, edit := range node.Entry.Edits {
+			apply(edit)
+		}
+	}
+	return table.String()
+}
+
+// LoadHistory replaces the buffer's undo history with the one saved for
+// filePath. The saved history is only used if it was written for the same
+// path and the same text the buffer holds now, and every edit in it still
+// applies cleanly; otherwise an error is returned and the buffer is left
+// untouched. A missing history file is reported with an error satisfying
+// errors.Is(err, fs.ErrNotExist).
+func LoadHistory(filePath string, b *Buffer) error {
+	path, err := undoFilePath(filePath)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var file undoFile
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		return fmt.Errorf("corrupt undo history %s: %w", path, err)
+	}
+	if file.Checksum != sha256.Sum256(file.Payload) {
+		return fmt.Errorf("corrupt undo history %s: checksum mismatch", path)
+	}
+	var saved savedHistory
+	if err := gob.NewDecoder(bytes.NewReader(file.Payload)).Decode(&saved); err != nil {
+		return fmt.Errorf("corrupt undo history %s: %w", path, err)
+	}
+	abs, _ := filepath.Abs(filePath)
+	if saved.Version != undoFileVersion || saved.Path != abs || saved.ContentHash != sha256.Sum256([]byte(b.String())) {
+		return errStaleHistory
+	}
+
+	tree, err := saved.tree()
+	if err != nil {
+		return fmt.Errorf("corrupt undo history %s: %w", path, err)
+	}
+	if err := verifyHistory(tree, b.String()); err != nil {
+		return fmt.Errorf("corrupt undo history %s: %w", path, err)
+	}
+	b.History = tree
+	return nil
+}
+
+// tree rebuilds the undo tree, checking that the node links make sense.
+func (saved *savedHistory) tree() (*UndoTree, error) {
+	if len(saved.Nodes) == 0 || saved.Nodes[0].Parent != -1 {
+		return nil, errors.New("missing root")
+	}
+	if saved.Current < 0 || saved.Current >= len(saved.Nodes) {
+		return nil, errors.New("current state out of range")
+	}
+
+	tree := &UndoTree{}
+	for i, sn := range saved.Nodes {
+		node := &UndoNode{
+			Entry: UndoEntry{
+				Edits:         sn.Edits,
+				CursorsBefore: sn.CursorsBefore,
+				CursorsAfter:  sn.CursorsAfter,
+				sealed:        true,
+			},
+			Seq:  sn.Seq,
+			Time: sn.Time,
+			redo: sn.Redo,
+		}
+		if i > 0 {
+			if sn.Parent < 0 || sn.Parent >= i || len(sn.Edits) == 0 {
+				return nil, fmt.Errorf("bad node %d", i)
+			}
+			node.Parent = tree.nodes[sn.Parent]
+			node.Parent.Children = append(node.Parent.Children, node)
+		}
+		tree.nodes = append(tree.nodes, node)
+		tree.size += node.Entry.size()
+		tree.nextSeq = max(tree.nextSeq, sn.Seq+1)
+	}
+	for _, node := range tree.nodes {
+		if node.redo < 0 || node.redo >= max(len(node.Children), 1) {
+			return nil, errors.New("bad redo branch")
+		}
+	}
+
+	tree.Root = tree.nodes[0]
+	tree.Current = tree.nodes[saved.Current]
+	return tree, nil
+}
+
+// verifyHistory replays every edit in the tree starting from content, the
+// text of the current state, and checks that each one finds the text it
+// expects to replace.
+func verifyHistory(tree *UndoTree, content string) error {
+	table := NewPieceTable(content)
+	lines := strings.Count(content, "\n") + 1
+	check := func(edit Edit) error {
+		if edit.kind() == editEndings {
+			if len(edit.DeletedCRLF) != lines+1 || len(edit.InsertedCRLF) != lines+1 {
+				return errors.New("line ending conversion does not match the text")
+			}
+			return nil
+		}
+		if edit.Pos < 0 || edit.Pos+len(edit.Deleted) > table.Len() ||
+			table.Slice(edit.Pos, edit.Pos+len(edit.Deleted)) != edit.Deleted {
+			return errors.New("edit does not match the text")
+		}
+		table.Delete(edit.Pos, edit.Pos+len(edit.Deleted))
+		table.Insert(edit.Pos, edit.Inserted)
+		lines += strings.Count(edit.Inserted, "\n") - strings.Count(edit.Deleted, "\n")
+		return nil
+	}
+
+	// Walk back to the root, then visit every branch from there
+	for node := tree.Current; node != tree.Root; node = node.Parent {
+		for i := len(node.Entry.Edits) - 1; i >= 0; i-- {
+			if err := check(node.Entry.Edits[i].invert()); err != nil {
+				return err
+			}
+		}
+	}
+
+	var visit func(node *UndoNode) error
+	visit = func(node *UndoNode) error {
+		for _, child := range node.Children {
+			for _, edit := range child.Entry.Edits {
+				if err := check(edit); err != nil {
+					return err
+				}
+			}
+			if err := visit(child); err != nil {
+				return err
+			}
+			for i := len(child.Entry.Edits) - 1; i >= 0; i-- {
+				if err := check(child.Entry.Edits[i].invert()); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return visit(tree.Root)
+}
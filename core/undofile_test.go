@@ -0,0 +1,159 @@
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+// useTempCache makes undo histories go to a temporary cache directory.
+func useTempCache(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir) // Linux and BSD
+	t.Setenv("HOME", dir)           // macOS keeps the cache under ~/Library/Caches
+}
+
+// reopen opens the file of d again in a new workspace, with the undo history
+// saved for it.
+func reopen(t *testing.T, d *Document) (*Document, error) {
+	t.Helper()
+	d2, err := NewWorkspace().Open(d.Path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d2, d2.LoadHistory()
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	useTempCache(t)
+	d, _ := NewWorkspace().Open(writeFiles(t, "hello")[0], nil)
+	b := d.Buffer
+	b.Insert(5, " world")
+	b.SealUndo()
+	b.Undo()
+	b.Insert(0, "oh ") // A second branch
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SaveHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	d2, err := reopen(t, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2 := d2.Buffer
+	if d2.Dirty() || b2.History.Len() != b.History.Len() {
+		t.Fatalf("loaded %d states, want %d", b2.History.Len(), b.History.Len())
+	}
+	steps := []struct {
+		name string
+		move func() bool
+		want string
+	}{
+		{"undo", b2.Undo, "hello"},
+		{"redo", b2.Redo, "oh hello"},
+		{"switch branch", func() bool { return b2.SwitchBranch(1) }, "hello world"},
+	}
+	for _, step := range steps {
+		if !step.move() || b2.String() != step.want {
+			t.Fatalf("%s: %q, want %q", step.name, b2.String(), step.want)
+		}
+	}
+}
+
+func TestHistoryOfDirtyDocument(t *testing.T) {
+	useTempCache(t)
+	d, _ := NewWorkspace().Open(writeFiles(t, "one\r\ntwo")[0], nil)
+	b := d.Buffer
+	b.Insert(3, "!")
+	b.SealUndo()
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b.Insert(b.Len(), "\nthree") // Closed without saving this
+	b.SealUndo()
+	b.SetLineEnding(LineEndingLF)
+	if err := d.SaveHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	d2, err := reopen(t, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2 := d2.Buffer
+	if d2.Dirty() || b2.TextWithLineEndings() != "one!\r\ntwo" {
+		t.Fatalf("reopened as %q", b2.TextWithLineEndings())
+	}
+	if !b2.Redo() || !b2.Redo() || b2.TextWithLineEndings() != "one!\ntwo\nthree" {
+		t.Fatalf("redo of the unsaved edits: %q", b2.TextWithLineEndings())
+	}
+	for b2.Undo() {
+	}
+	if b2.TextWithLineEndings() != "one\r\ntwo" {
+		t.Fatalf("undo to the start: %q", b2.TextWithLineEndings())
+	}
+}
+
+func TestHistoryUnusable(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, d *Document) // After saving the history
+		corrupt bool
+		stale   bool
+	}{
+		{"missing", func(t *testing.T, d *Document) {
+			path, _ := undoFilePath(d.Path)
+			os.Remove(path)
+		}, false, false},
+		{"file changed", func(t *testing.T, d *Document) {
+			os.WriteFile(d.Path, []byte("changed"), 0644)
+		}, false, true},
+		{"damaged", func(t *testing.T, d *Document) {
+			path, _ := undoFilePath(d.Path)
+			data, _ := os.ReadFile(path)
+			data[len(data)/2] ^= 0xff
+			os.WriteFile(path, data, 0644)
+		}, true, false},
+		{"truncated", func(t *testing.T, d *Document) {
+			path, _ := undoFilePath(d.Path)
+			data, _ := os.ReadFile(path)
+			os.WriteFile(path, data[:len(data)/2], 0644)
+		}, true, false},
+		{"edits that don't apply", func(t *testing.T, d *Document) {
+			d.Buffer.History.Root.Children[0].Entry.Edits[0].Pos = 100
+			d.SaveHistory()
+		}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempCache(t)
+			d, _ := NewWorkspace().Open(writeFiles(t, "hello")[0], nil)
+			d.Buffer.Insert(0, "x")
+			d.Save()
+			if err := d.SaveHistory(); err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t, d)
+
+			d2, err := reopen(t, d)
+			switch {
+			case err == nil:
+				t.Fatal("the history was loaded")
+			case !tt.corrupt && !tt.stale && !errors.Is(err, fs.ErrNotExist):
+				t.Errorf("missing history reported as %v", err)
+			case tt.stale && err != errStaleHistory:
+				t.Errorf("stale history reported as %v", err)
+			case tt.corrupt && err == errStaleHistory:
+				t.Errorf("corrupt history reported as stale")
+			}
+			if d2.Buffer.History.Len() != 1 || d2.Buffer.Undo() {
+				t.Error("the buffer got a history anyway")
+			}
+		})
+	}
+}
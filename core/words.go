@@ -0,0 +1,153 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// WordRules say which characters make up words in a language. Letters,
+// digits, combining marks and underscores always do.
+type WordRules struct {
+	Extra string // Punctuation that is part of words too, such as the hyphen in CSS
+}
+
+// LanguageWordRules maps file extensions to the word rules of their
+// language. Files of other languages, Go among them, use the zero
+// WordRules.
+var LanguageWordRules = map[string]WordRules{
+	".css":  {Extra: "-"},
+	".scss": {Extra: "-"},
+	".less": {Extra: "-"},
+	".html": {Extra: "-"},
+	".lisp": {Extra: "-"},
+	".el":   {Extra: "-"},
+	".clj":  {Extra: "-?!"},
+	".php":  {Extra: "$"},
+}
+
+// WordRulesFor returns the word rules for the language of the file at path.
+func WordRulesFor(path string) WordRules {
+	return LanguageWordRules[strings.ToLower(filepath.Ext(path))]
+}
+
+// isWordRune reports whether r is part of a word.
+func (w WordRules) isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || strings.ContainsRune(w.Extra, r)
+}
+
+// isJoiner reports whether r is a word rune that only joins the parts of a
+// word, like the underscore in snake_case. Subword motion skips over it.
+func (w WordRules) isJoiner(r rune) bool {
+	return r == '_' || strings.ContainsRune(w.Extra, r)
+}
+
+// runeClass sorts runes into spaces, word runes and punctuation, for finding
+// word boundaries. With subwords, joiners count as spaces.
+func (w WordRules) runeClass(r rune, subword bool) int {
+	switch {
+	case unicode.IsSpace(r) || subword && w.isJoiner(r):
+		return 0
+	case w.isWordRune(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// humpAt reports whether a camelCase word has a new part starting at
+// runes[i]: a capital after a lowercase letter or digit, or the last capital
+// of an acronym followed by a lowercase letter, as in HTTPServer.
+func humpAt(runes []rune, i int) bool {
+	if i == 0 || i >= len(runes) || !unicode.IsUpper(runes[i]) {
+		return false
+	}
+	prev := runes[i-1]
+	if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+}
+
+// wordRight returns the rune column in runes at the end of the word at or
+// after col, skipping spaces before it. A run of punctuation counts as a
+// word. With subword, camelCase humps and joiners end words too.
+func (w WordRules) wordRight(runes []rune, col int, subword bool) int {
+	i := col
+	for i < len(runes) && w.runeClass(runes[i], subword) == 0 {
+		i++
+	}
+	if i == len(runes) {
+		return i
+	}
+	class := w.runeClass(runes[i], subword)
+	i++
+	for i < len(runes) && w.runeClass(runes[i], subword) == class && !(subword && humpAt(runes, i)) {
+		i++
+	}
+	return i
+}
+
+// wordLeft returns the rune column in runes at the start of the word
+// before col, like wordRight going the other way.
+func (w WordRules) wordLeft(runes []rune, col int, subword bool) int {
+	i := col
+	for i > 0 && w.runeClass(runes[i-1], subword) == 0 {
+		i--
+	}
+	if i == 0 {
+		return 0
+	}
+	class := w.runeClass(runes[i-1], subword)
+	i--
+	for i > 0 && w.runeClass(runes[i-1], subword) == class && !(subword && humpAt(runes, i)) {
+		i--
+	}
+	return i
+}
+
+// WordAt returns the rune columns [start, end) of the word on row that
+// contains col or ends at it. ok is false if there is no word there.
+func (b *Buffer) WordAt(row, col int) (start, end int, ok bool) {
+	runes := []rune(b.Line(row))
+	col = max(0, min(col, len(runes)))
+	if col == len(runes) || !b.Words.isWordRune(runes[col]) {
+		if col == 0 || !b.Words.isWordRune(runes[col-1]) {
+			return 0, 0, false
+		}
+		col--
+	}
+	start, end = col, col
+	for start > 0 && b.Words.isWordRune(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && b.Words.isWordRune(runes[end]) {
+		end++
+	}
+	return start, end, true
+}
+
+// NextWord returns the position at the end of the next word after row and
+// col, or the start of the next line at the end of a line.
+func (b *Buffer) NextWord(row, col int, subword bool) (int, int) {
+	runes := []rune(b.Line(row))
+	if col >= len(runes) {
+		if row+1 < b.LineCount() {
+			return row + 1, 0
+		}
+		return row, len(runes)
+	}
+	return row, b.Words.wordRight(runes, col, subword)
+}
+
+// PrevWord returns the position at the start of the word before row and
+// col, or the end of the previous line at the start of a line.
+func (b *Buffer) PrevWord(row, col int, subword bool) (int, int) {
+	if col <= 0 {
+		if row > 0 {
+			return row - 1, b.LineLen(row - 1)
+		}
+		return 0, 0
+	}
+	return row, b.Words.wordLeft([]rune(b.Line(row)), col, subword)
+}
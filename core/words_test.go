@@ -0,0 +1,126 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWordStops(t *testing.T) {
+	const line = "func (b *Buffer) fooBar_baz(HTTPServer x) {"
+	b := NewBuffer()
+	b.Load(line + "\n  next")
+	tests := []struct {
+		name    string
+		from    int
+		subword bool
+		next    bool
+		want    []int
+	}{
+		{"words right", 0, false, true, []int{4, 6, 7, 9, 15, 16, 27, 28, 38, 40, 41, 43}},
+		{"words left", 43, false, false, []int{42, 40, 39, 28, 27, 17, 15, 9, 8, 6, 5, 0}},
+		{"subwords right", 17, true, true, []int{20, 23, 27, 28, 32, 38}},
+		{"subwords left", 38, true, false, []int{32, 28, 27, 24, 20, 17}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stops []int
+			col := tt.from
+			for range tt.want {
+				var row int
+				if tt.next {
+					row, col = b.NextWord(0, col, tt.subword)
+				} else {
+					row, col = b.PrevWord(0, col, tt.subword)
+				}
+				if row != 0 {
+					t.Fatalf("left the line after stopping at %v", stops)
+				}
+				stops = append(stops, col)
+			}
+			if !slices.Equal(stops, tt.want) {
+				t.Errorf("stops %v, want %v", stops, tt.want)
+			}
+		})
+	}
+
+	// Across lines, the line break is a stop of its own
+	lineEnd := len([]rune(line))
+	moves := []struct {
+		name                       string
+		row, col, wantRow, wantCol int
+		next                       bool
+	}{
+		{"end of line", 0, lineEnd, 1, 0, true},
+		{"next line", 1, 0, 1, 6, true},
+		{"start of line", 1, 0, 0, lineEnd, false},
+		{"last line", 1, 6, 1, 6, true},
+		{"first line", 0, 0, 0, 0, false},
+	}
+	for _, m := range moves {
+		var row, col int
+		if m.next {
+			row, col = b.NextWord(m.row, m.col, false)
+		} else {
+			row, col = b.PrevWord(m.row, m.col, false)
+		}
+		if row != m.wantRow || col != m.wantCol {
+			t.Errorf("%s: moved to %d, %d, want %d, %d", m.name, row, col, m.wantRow, m.wantCol)
+		}
+	}
+}
+
+func TestDeleteWord(t *testing.T) {
+	b, cm := trackedBuffer("hello brave_newWorld")
+	cm.Cursors[0] = Cursor{Row: 0, Col: 20}
+	steps := []struct {
+		name string
+		edit func()
+		want string
+	}{
+		{"subword left", func() { DeleteWordLeft(b, cm, true) }, "hello brave_new"},
+		{"word left", func() { DeleteWordLeft(b, cm, false) }, "hello "},
+		{"undone in one step", func() { b.Undo() }, "hello brave_newWorld"},
+		{"word right", func() { cm.Cursors[0] = Cursor{}; DeleteWordRight(b, cm, false) }, " brave_newWorld"},
+		{"selection instead of the word", func() {
+			MoveLineEnd(b, cm, false)
+			MoveWordLeft(b, cm, true, true)
+			DeleteWordRight(b, cm, false)
+		}, " brave_new"},
+	}
+	for _, step := range steps {
+		step.edit()
+		if b.String() != step.want {
+			t.Fatalf("%s: %q, want %q", step.name, b.String(), step.want)
+		}
+	}
+}
+
+func TestWordRulesFor(t *testing.T) {
+	b := NewBuffer()
+	b.Load("margin-top: $x")
+	tests := []struct {
+		path    string
+		subword bool
+		want    int
+	}{
+		{"main.go", false, 6},
+		{"style.CSS", false, 10},
+		{"style.css", true, 6}, // The hyphen joins subwords
+		{"index.php", false, 6},
+	}
+	for _, tt := range tests {
+		b.Words = WordRulesFor(tt.path)
+		if _, got := b.NextWord(0, 0, tt.subword); got != tt.want {
+			t.Errorf("%s: first word ends at %d, want %d", tt.path, got, tt.want)
+		}
+	}
+
+	b.Words = WordRulesFor("style.css")
+	if start, end, ok := b.WordAt(0, 8); !ok || start != 0 || end != 10 {
+		t.Errorf("WordAt(0, 8) = %d, %d, %v in CSS, want 0, 10", start, end, ok)
+	}
+	b.Words = WordRulesFor("index.php")
+	if start, end, ok := b.WordAt(0, 14); !ok || start != 12 || end != 14 {
+		t.Errorf("WordAt(0, 14) = %d, %d, %v in PHP, want 12, 14", start, end, ok)
+	}
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrUnsavedChanges is returned when closing a document with changes that
+// were never saved.
+var ErrUnsavedChanges = errors.New("document has unsaved changes")
+
+// Document is one open file: its text with undo history, its own cursors
+// and where it was scrolled to.
+type Document struct {
+	Path    string
+	Buffer  *Buffer
+	Cursors *CursorManager
+	ScrollY float32 // How far the view is scrolled, kept while other documents are shown
+
+	saved *UndoNode // History state that matches the file on disk
+}
+
+// NewDocument makes a document for path holding text. It counts as saved.
+func NewDocument(path, text string) *Document {
+	doc := &Document{Path: path, Buffer: NewBuffer(), Cursors: NewCursorManager()}
+	doc.Buffer.Load(text)
+	doc.Buffer.TrackCursors(doc.Cursors) // After loading, so the cursor starts at the top
+	doc.saved = doc.Buffer.History.Current
+	return doc
+}
+
+// Name returns the file name of the document, for lists and tabs.
+func (d *Document) Name() string {
+	return filepath.Base(d.Path)
+}
+
+// Dirty reports whether the text differs from what was last loaded or saved.
+// Undoing back to the saved state makes the document clean again.
+func (d *Document) Dirty() bool {
+	return d.Buffer.History.Current != d.saved
+}
+
+// Save writes the text to the document's path in its encoding and line
+// endings, see writeFileAtomic. Read-only documents are not saved.
+func (d *Document) Save() error {
+	if d.Buffer.ReadOnly() {
+		return fmt.Errorf("failed to save %s: %w", d.Path, ErrReadOnly)
+	}
+	data, err := Encode(d.Buffer.TextWithLineEndings(), d.Buffer.Encoding)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", d.Path, err)
+	}
+	if err := writeFileAtomic(d.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", d.Path, err)
+	}
+	d.Buffer.SealUndo() // Typing after a save starts a new step, so undo can reach the saved state
+	d.saved = d.Buffer.History.Current
+	return nil
+}
+
+// LoadHistory restores the undo history saved for the document, see
+// LoadHistory.
+func (d *Document) LoadHistory() error {
+	dirty := d.Dirty()
+	if err := LoadHistory(d.Path, d.Buffer); err != nil {
+		return err
+	}
+	if !dirty {
+		d.saved = d.Buffer.History.Current
+	}
+	return nil
+}
+
+// SaveHistory saves the undo history of the document as of its last save,
+// see SaveHistory.
+func (d *Document) SaveHistory() error {
+	return SaveHistory(d.Path, d.Buffer, d.saved)
+}
+
+// Workspace holds every open document and knows which one is active.
+type Workspace struct {
+	Documents []*Document
+	Active    int  // Index of the active document, -1 when there is none
+	ReadOnly  bool // Open every document read-only
+}
+
+func NewWorkspace() *Workspace {
+	return &Workspace{Active: -1}
+}
+
+// Current returns the active document, or nil if nothing is open.
+func (w *Workspace) Current() *Document {
+	if w.Active < 0 || w.Active >= len(w.Documents) {
+		return nil
+	}
+	return w.Documents[w.Active]
+}
+
+// Index returns the position of the document open at path, or -1.
+func (w *Workspace) Index(path string) int {
+	abs, _ := filepath.Abs(path)
+	for i, doc := range w.Documents {
+		if docAbs, _ := filepath.Abs(doc.Path); docAbs == abs {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add adds doc to the workspace and makes it active.
+func (w *Workspace) Add(doc *Document) {
+	w.Documents = append(w.Documents, doc)
+	w.Active = len(w.Documents) - 1
+}
+
+// Open makes the file at path the active document, reading it unless it is
+// already open. The encoding is detected unless a charset override is given.
+// Files that can't be written to are opened read-only.
+func (w *Workspace) Open(path string, override *Charset) (*Document, error) {
+	if i := w.Index(path); i >= 0 {
+		w.Active = i
+		return w.Documents[i], nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text, enc := DecodeFile(data, override)
+	doc := NewDocument(path, text)
+	doc.Buffer.Encoding = enc
+	doc.Buffer.Words = WordRulesFor(path)
+	doc.Buffer.SetReadOnly(w.ReadOnly || !writable(path))
+	w.Add(doc)
+	return doc, nil
+}
+
+// Close closes document i. A document with unsaved changes is only closed if
+// discard is set; otherwise ErrUnsavedChanges is returned.
+func (w *Workspace) Close(i int, discard bool) error {
+	if i < 0 || i >= len(w.Documents) {
+		return nil
+	}
+	if w.Documents[i].Dirty() && !discard {
+		return ErrUnsavedChanges
+	}
+	w.Documents = append(w.Documents[:i], w.Documents[i+1:]...)
+	if w.Active > i || w.Active == len(w.Documents) {
+		w.Active--
+	}
+	return nil
+}
+
+// Unsaved returns the documents with changes that were never saved, which
+// Close refuses to close without discard.
+func (w *Workspace) Unsaved() []*Document {
+	var unsaved []*Document
+	for _, doc := range w.Documents {
+		if doc.Dirty() {
+			unsaved = append(unsaved, doc)
+		}
+	}
+	return unsaved
+}
+
+// Switch makes document i active.
+func (w *Workspace) Switch(i int) {
+	if i >= 0 && i < len(w.Documents) {
+		w.Active = i
+	}
+}
+
+// Cycle moves delta documents to the right (or left when negative) of the
+// active one, wrapping around.
+func (w *Workspace) Cycle(delta int) {
+	if len(w.Documents) == 0 {
+		return
+	}
+	w.Active = ((w.Active+delta)%len(w.Documents) + len(w.Documents)) % len(w.Documents)
+}
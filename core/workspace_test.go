@@ -0,0 +1,191 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes each of files, named by their base name, into a new
+// temporary directory and returns their paths in the same order.
+func writeFiles(t *testing.T, files ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, len(files))
+	for i, content := range files {
+		paths[i] = filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(paths[i], []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func TestWorkspaceOpen(t *testing.T) {
+	paths := writeFiles(t, "alpha", "beta")
+	w := NewWorkspace()
+	if w.Current() != nil {
+		t.Fatal("empty workspace has a current document")
+	}
+	a, err := w.Open(paths[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := w.Open(paths[1], nil)
+	if w.Current() != b {
+		t.Fatal("the opened document isn't active")
+	}
+	if d, _ := w.Open(paths[0], nil); d != a || w.Active != 0 || len(w.Documents) != 2 {
+		t.Fatal("opening an open file didn't switch to it")
+	}
+
+	a.Cursors.GetPrimary().Col = 5
+	InsertAtCursor(a.Buffer, "!", 0, 5)
+	if a.Cursors.GetPrimary().Col != 6 || b.Cursors.GetPrimary().Col != 0 {
+		t.Fatal("documents share cursors")
+	}
+
+	cycles := []struct {
+		delta int
+		want  *Document
+	}{{1, b}, {1, a}, {-1, b}, {-2, b}}
+	for _, c := range cycles {
+		w.Cycle(c.delta)
+		if w.Current() != c.want {
+			t.Fatalf("Cycle(%d) made %s active", c.delta, w.Current().Name())
+		}
+	}
+}
+
+func TestDocumentDirty(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(d *Document)
+		want bool
+	}{
+		{"unchanged", func(d *Document) {}, false},
+		{"edited", func(d *Document) { d.Buffer.Insert(0, "x") }, true},
+		{"undone", func(d *Document) {
+			d.Buffer.Insert(0, "x")
+			d.Buffer.Undo()
+		}, false},
+		{"line endings converted", func(d *Document) { d.Buffer.SetLineEnding(LineEndingLF) }, true},
+		{"saved", func(d *Document) {
+			d.Buffer.Insert(0, "x")
+			d.Save()
+		}, false},
+		{"edited after saving", func(d *Document) {
+			d.Buffer.Insert(0, "x")
+			d.Save()
+			d.Buffer.Insert(1, "y")
+		}, true},
+		{"undone to the save", func(d *Document) {
+			d.Buffer.Insert(0, "x")
+			d.Save()
+			d.Buffer.Insert(1, "y")
+			d.Buffer.Undo()
+		}, false},
+		{"undone past the save", func(d *Document) {
+			d.Buffer.Insert(0, "x")
+			d.Save()
+			d.Buffer.Undo()
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkspace()
+			d, err := w.Open(writeFiles(t, "one\r\ntwo")[0], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(d)
+			if d.Dirty() != tt.want {
+				t.Errorf("Dirty() = %v, want %v", d.Dirty(), tt.want)
+			}
+			if unsaved := w.Unsaved(); (len(unsaved) == 1) != tt.want {
+				t.Errorf("Unsaved() = %v", unsaved)
+			}
+		})
+	}
+}
+
+func TestWorkspaceClose(t *testing.T) {
+	tests := []struct {
+		name       string
+		active     int
+		close      int
+		dirty      bool
+		discard    bool
+		err        error
+		wantActive int
+	}{
+		{"clean", 0, 0, false, false, nil, 0},
+		{"dirty", 0, 0, true, false, ErrUnsavedChanges, 0},
+		{"dirty discarded", 0, 0, true, true, nil, 0},
+		{"before the active one", 2, 0, false, false, nil, 1},
+		{"after the active one", 0, 2, false, false, nil, 0},
+		{"the active last one", 2, 2, false, false, nil, 1},
+		{"out of range", 1, 5, false, false, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkspace()
+			for _, path := range writeFiles(t, "a", "b", "c") {
+				w.Open(path, nil)
+			}
+			w.Switch(tt.active)
+			if tt.dirty {
+				w.Documents[tt.close].Buffer.Insert(0, "x")
+			}
+			count := len(w.Documents)
+			if err := w.Close(tt.close, tt.discard); err != tt.err {
+				t.Fatalf("Close() = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && tt.close < count {
+				count--
+			}
+			if len(w.Documents) != count || w.Active != tt.wantActive {
+				t.Errorf("%d documents with %d active, want %d with %d active", len(w.Documents), w.Active, count, tt.wantActive)
+			}
+		})
+	}
+
+	w := NewWorkspace()
+	w.Open(writeFiles(t, "a")[0], nil)
+	if w.Close(0, false); w.Current() != nil || w.Active != -1 {
+		t.Errorf("closing the only document left %d active", w.Active)
+	}
+}
+
+func TestDocumentSave(t *testing.T) {
+	path := writeFiles(t, "alpha\r\nbeta\r\n")[0]
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(filepath.Dir(path), "link.txt")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+	w := NewWorkspace()
+	d, err := w.Open(link, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Buffer.Insert(5, "!")
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "alpha!\r\nbeta\r\n" {
+		t.Errorf("saved %q", data)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Error("the symlink was replaced by the file")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("saving changed the permissions to %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 2 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
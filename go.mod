@@ -2,4 +2,7 @@ module github.com/mariownyou/go-text-editor
 
 go 1.23.4
 
-require github.com/veandco/go-sdl2 v0.4.40 // indirect
+require (
+	github.com/rivo/uniseg v0.4.7
+	github.com/veandco/go-sdl2 v0.4.40
+)
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/mariownyou/go-text-editor/core"
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
 )
@@ -13,7 +19,9 @@ const (
 	fontPath        = "FiraCode-Regular.ttf"
 	fontSize        = 14
 	scrollSpeed     = 100
-	scrollLerpSpeed = 0.1 // smaller = slower
+	scrollLerpSpeed = 0.1         // smaller = slower
+	historyTimeStep = time.Minute // how far Earlier/Later jump per key press
+	noticeDuration  = 3000        // how long a notice stays in the status bar, in ms
 )
 
 var (
@@ -36,47 +44,203 @@ var (
 	lastFPSUpdate = sdl.GetTicks64()
 )
 
-var cursorManager = NewCursorManager()
-var isDragging = false
+var workspace = core.NewWorkspace()
+var cursorManager *core.CursorManager  // Cursors of the active document
+var isDragging = false                 // The left button went down in the text and is still held
+var mouseSelection core.MouseSelection // Selection being made with the mouse
 
-func saveBufferToFile(bufferText string, filePath string) error {
-	if filePath == "" {
-		filePath = "buffer.txt"
+var layouts = map[*core.Buffer]*core.LayoutCache{} // Line layouts of open documents, made when first drawn
+
+var historyPanel listPanel // Rows are buffer.History.Nodes(), newest first
+
+var largeFileTop = 0 // first row shown in large-file mode
+
+var (
+	notice      = ""        // shown in the status bar until noticeUntil
+	noticeUntil = uint64(0) // in sdl.GetTicks64 time
+)
+
+var documentPanel listPanel // Rows are workspace.Documents
+
+var (
+	bookmarkPanel   listPanel // Rows are bookmarkEntries
+	bookmarkEntries []core.BookmarkEntry
+
+	revealCursor = false // scroll the primary cursor into view after the next render
+)
+
+// openDocument opens path in the workspace, restoring its undo history and
+// the bookmarks saved in session.
+func openDocument(path string, charset *core.Charset, session *core.Session) (*core.Document, error) {
+	if i := workspace.Index(path); i >= 0 {
+		switchDocument(i)
+		return workspace.Current(), nil
 	}
-	data := []byte(bufferText)
-	err := os.WriteFile(filePath, data, 0644)
+	keepScroll()
+	doc, err := workspace.Open(path, charset)
 	if err != nil {
-		return fmt.Errorf("failed to save buffer to file: %w", err)
+		return nil, err
+	}
+	showCurrent()
+	if charset != nil && doc.Buffer.Encoding.Charset != *charset {
+		fmt.Println(path, "can't be read as", core.Encoding{Charset: *charset}, "without losing data, using", doc.Buffer.Encoding)
+	}
+	if err := doc.LoadHistory(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Println("Ignoring undo history:", err)
 	}
+	if f := session.File(path); f != nil {
+		doc.Buffer.SetBookmarks(f.Bookmarks)
+	}
+	return doc, nil
+}
+
+// closeDocument saves the undo history and bookmarks of document i and
+// closes it. Unsaved changes are only thrown away if discard is set.
+func closeDocument(i int, discard bool, session *core.Session) error {
+	doc := workspace.Documents[i]
+	if err := workspace.Close(i, discard); err != nil {
+		return err
+	}
+	if err := doc.SaveHistory(); err != nil {
+		fmt.Println("Error saving undo history:", err)
+	}
+	session.SetBookmarks(doc.Path, doc.Buffer.Bookmarks())
+	if c := layouts[doc.Buffer]; c != nil {
+		c.Close()
+		delete(layouts, doc.Buffer)
+	}
+	showCurrent()
 	return nil
 }
 
-func main() {
-	bufferText := ""
-	filePath := ""
+// canQuit saves the scratch document and reports whether the editor may
+// quit. Other documents with unsaved changes keep it open, listed in a
+// notice, unless discard is set.
+func canQuit(scratch *core.Document, discard bool) bool {
+	if scratch != nil && workspace.Index(scratch.Path) >= 0 && !scratch.Buffer.ReadOnly() {
+		if err := scratch.Save(); err != nil {
+			fmt.Println("Error:", err)
+		}
+	}
+	unsaved := workspace.Unsaved()
+	if len(unsaved) == 0 || discard {
+		return true
+	}
+	names := make([]string, len(unsaved))
+	for i, doc := range unsaved {
+		names[i] = doc.Name()
+	}
+	showNotice(strings.Join(names, ", ") + " not saved; save with Ctrl+S or quit with Shift+Escape to discard the changes")
+	return false
+}
+
+// showNotice shows msg in the status bar for a few seconds.
+func showNotice(msg string) {
+	fmt.Println(msg)
+	notice = msg
+	noticeUntil = sdl.GetTicks64() + noticeDuration
+}
+
+// editable reports whether buffer may be changed, telling the user why not
+// if it's read-only.
+func editable(buffer *core.Buffer) bool {
+	if buffer.ReadOnly() {
+		showNotice("Read-only: the document can't be changed")
+		return false
+	}
+	return true
+}
 
-	buffer := NewBuffer()
+// switchDocument shows document i.
+func switchDocument(i int) {
+	keepScroll()
+	workspace.Switch(i)
+	showCurrent()
+}
+
+// keepScroll remembers the scroll position of the active document so it can
+// be restored when the document is shown again.
+func keepScroll() {
+	if doc := workspace.Current(); doc != nil {
+		doc.ScrollY = targetScrollOffsetY
+	}
+}
+
+// showCurrent makes the active document the one edited and drawn.
+func showCurrent() {
+	doc := workspace.Current()
+	if doc == nil {
+		return
+	}
+	cursorManager = doc.Cursors
+	targetScrollOffsetY, actualScrollOffsetY = doc.ScrollY, doc.ScrollY
+	historyPanel.Open = false
+}
 
-	if len(os.Args) > 1 {
-		filePath = os.Args[1]
-		data, err := os.ReadFile(filePath)
+func main() {
+	encodingName := flag.String("encoding", "", "read the file as utf-8, utf-16le, utf-16be or latin-1 instead of detecting it")
+	readOnly := flag.Bool("readonly", false, "open files for reading only, refusing any edit")
+	flag.Parse()
+	workspace.ReadOnly = *readOnly
+
+	var charset *core.Charset
+	if *encodingName != "" {
+		c, err := core.ParseCharset(*encodingName)
 		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		charset = &c
+	}
+
+	session, err := core.LoadSession()
+	if err != nil {
+		fmt.Println("Ignoring saved session:", err)
+	}
+
+	// A single file too big to edit is shown read-only; among several it is
+	// left out
+	var large *core.LargeFile
+	for _, path := range flag.Args() {
+		if info, err := os.Stat(path); err == nil && info.Size() > core.LargeFileThreshold {
+			if flag.NArg() > 1 {
+				fmt.Println("Skipping", path, "since it is too large to open next to other files")
+				continue
+			}
+			large, err = core.OpenLargeFile(path, charset)
+			if err != nil {
+				fmt.Println("Error reading file:", err)
+				return
+			}
+			defer large.Close()
+			fmt.Println("Opening", path, "read-only in large-file mode")
+			continue
+		}
+		if _, err := openDocument(path, charset, session); err != nil {
 			fmt.Println("Error reading file:", err)
 			return
 		}
-		bufferText = string(data)
-	} else {
-		// read from buffer.txt if no file is provided
-		data, err := os.ReadFile("buffer.txt")
+	}
+
+	// Without files to open, edit buffer.txt, which is saved on quit
+	var scratch *core.Document
+	if flag.NArg() == 0 {
+		var err error
+		scratch, err = openDocument("buffer.txt", charset, session)
 		if err != nil {
 			fmt.Println("Error reading buffer.txt:", err)
-			bufferText = "Hello, High-DPI World!\nasdadasda"
-		} else {
-			bufferText = string(data)
+			scratch = core.NewDocument("buffer.txt", "Hello, High-DPI World!\nasdadasda")
+			scratch.Buffer.SetReadOnly(workspace.ReadOnly)
+			workspace.Add(scratch)
+			showCurrent()
 		}
 	}
-
-	buffer.SetContent(bufferText)
+	if large == nil && len(workspace.Documents) == 0 {
+		return
+	}
+	if len(workspace.Documents) > 0 {
+		switchDocument(0)
+	}
 
 	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
 		panic(err)
@@ -120,26 +284,42 @@ func main() {
 	defer atlas.Destroy()
 
 	running := true
+mainLoop:
 	for running {
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			doc := workspace.Current() // Nil in large-file mode
+			var buffer *core.Buffer
+			if doc != nil {
+				buffer = doc.Buffer
+			}
 			switch e := event.(type) {
 			case *sdl.QuitEvent:
-				if filePath == "" {
-					saveBufferToFile(buffer.Content, "")
+				running = !canQuit(scratch, false)
+			case *sdl.DropEvent:
+				if e.Type == sdl.DROPFILE && large == nil {
+					if _, err := openDocument(e.File, charset, session); err != nil {
+						fmt.Println("Error opening dropped file:", err)
+					}
 				}
-				running = false
 			case *sdl.WindowEvent:
 				if e.Event == sdl.WINDOWEVENT_RESIZED || e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
 					// Update renderer size
 					rw, _, _ = renderer.GetOutputSize()
 				}
 			case *sdl.MouseWheelEvent:
+				if large != nil {
+					scrollLargeFile(large, -int(e.Y)*3)
+					continue
+				}
 				scrollAmount := float32(e.Y) * scrollSpeed // adjust multiplier to taste
 				targetScrollOffsetY -= scrollAmount
 				if targetScrollOffsetY < 0 {
 					targetScrollOffsetY = 0
 				}
 			case *sdl.MouseButtonEvent:
+				if large != nil {
+					continue // Large files are read-only and have no cursor
+				}
 				if e.Button != sdl.BUTTON_LEFT {
 					continue // Only handle left mouse button events
 				}
@@ -147,98 +327,226 @@ func main() {
 				x, y := e.X, e.Y
 				x, y = GetRealMousePos(x, y, window, renderer)
 				y += scrollOffsetY // Adjust for scroll offset
-				row, col := GetRowColFromClick(x, y, buffer.Content, atlas, renderer)
-				primary := cursorManager.GetPrimary()
+				row, col := GetRowColFromClick(x, y, buffer, atlas, renderer)
 
-				if e.Type == sdl.MOUSEBUTTONDOWN {
-					if !primary.Selection.Active && !isDragging ||
-						(primary.Selection.Active && (primary.Selection.StartRow != row || primary.Selection.StartCol != col)) {
-						isDragging = true
-						// Start new selection from current cursor position
-						primary.Selection.StartRow = row
-						primary.Selection.StartCol = col
+				if sdl.GetModState()&sdl.KMOD_ALT != 0 {
+					// Alt+click adds a cursor and leaves the others alone
+					if e.Type == sdl.MOUSEBUTTONDOWN {
+						buffer.SealUndo()
+						cursorManager.AddCursor(core.Cursor{Row: row, Col: col})
 					}
-					primary.Row, primary.Col = row, col
+					continue
+				}
+				if e.Type == sdl.MOUSEBUTTONDOWN {
+					buffer.SealUndo() // Clicking moves the cursor, so typing starts a new undo step
+					cursorManager.ClearSecondary()
+					extend := sdl.GetModState()&sdl.KMOD_SHIFT != 0
+					mouseSelection.Click(buffer, cursorManager.GetPrimary(), row, col, int(e.Clicks), extend)
+					isDragging = true
 				} else if e.Type == sdl.MOUSEBUTTONUP {
-					// If we dragged, finalize the selection
-					if !isDragging {
-						cursorManager.ClearAllSelections()
-						primary.Selection.Active = false
-					}
 					isDragging = false
-
-					if primary.Selection.Active && (primary.Selection.StartRow != row || primary.Selection.StartCol != col) {
-						primary.Selection.EndRow = row
-						primary.Selection.EndCol = col
-					}
 				}
 			case *sdl.MouseMotionEvent:
-				if e.State == sdl.PRESSED {
+				if isDragging && large == nil {
 					x, y := e.X, e.Y
 					x, y = GetRealMousePos(x, y, window, renderer)
 					y += scrollOffsetY
-					row, col := GetRowColFromClick(x, y, buffer.Content, atlas, renderer)
+					row, col := GetRowColFromClick(x, y, buffer, atlas, renderer)
 
-					primary := cursorManager.GetPrimary()
-					primary.Selection.Active = true
-					if primary.Selection.StartRow != row || primary.Selection.StartCol != col {
-						primary.Selection.Active = true
-						primary.Selection.EndRow = row
-						primary.Selection.EndCol = col
-						primary.Row = row
-						primary.Col = col
-					}
+					mouseSelection.Drag(buffer, cursorManager.GetPrimary(), row, col)
 				}
 			case *sdl.KeyboardEvent:
+				if large != nil {
+					if e.Type == sdl.KEYDOWN {
+						if e.Keysym.Sym == sdl.K_ESCAPE {
+							running = false
+						}
+						handleLargeFileKey(e, large, renderer, atlas)
+					}
+					continue
+				}
+				if historyPanel.Open {
+					nodes := buffer.History.Nodes()
+					if e.Type == sdl.KEYDOWN && handleListPanelKey(e, &historyPanel, len(nodes), sdl.K_h) && editable(buffer) {
+						buffer.GoTo(nodes[len(nodes)-1-historyPanel.Selected])
+						core.ClampCursor(buffer, cursorManager.GetPrimary())
+					}
+					continue
+				}
+				if bookmarkPanel.Open {
+					if e.Type == sdl.KEYDOWN && handleListPanelKey(e, &bookmarkPanel, len(bookmarkEntries), sdl.K_b) {
+						entry := bookmarkEntries[bookmarkPanel.Selected]
+						if doc, err := openDocument(entry.Path, charset, session); err != nil {
+							fmt.Println("Error opening", entry.Path+":", err)
+						} else {
+							jumpToLine(doc.Buffer, entry.Row)
+							bookmarkPanel.Open = false
+						}
+					}
+					continue
+				}
+				if documentPanel.Open {
+					if e.Type == sdl.KEYDOWN && handleListPanelKey(e, &documentPanel, len(workspace.Documents), sdl.K_o) {
+						switchDocument(documentPanel.Selected)
+						documentPanel.Open = false
+					}
+					continue
+				}
 				primary := cursorManager.GetPrimary()
 				if e.Type == sdl.KEYDOWN {
+					extend := e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0 // Shift with a movement key selects
+					// Alt or Ctrl moves and deletes by word, both together by camelCase or snake_case part
+					byWord := e.Keysym.Mod&uint16(sdl.KMOD_ALT|sdl.KMOD_CTRL) != 0
+					subword := e.Keysym.Mod&uint16(sdl.KMOD_ALT) != 0 && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0
 					switch e.Keysym.Sym {
 					case sdl.K_BACKSPACE:
-						if cursorManager.HasSelection() {
-							buffer.SetContent(DeleteSelectedText(buffer.Content, cursorManager))
-							cursorManager.ClearAllSelections()
+						if !editable(buffer) {
 							continue
 						}
-						or := strings.Split(buffer.Content, "\n")[primary.Row]
-						ol := len([]rune(or))
-						buffer.SetContent(deleteAtCursor(buffer.Content, primary.Row, primary.Col))
-						if primary.Col > 0 || primary.Row == 0 {
-							primary.Col--
+						if byWord {
+							core.DeleteWordLeft(buffer, cursorManager, subword)
 						} else {
-							nr := strings.Split(buffer.Content, "\n")[primary.Row-1]
-							nl := len([]rune(nr))
-							primary.Row--
-							primary.Col = nl - ol
+							core.DeleteAtCursors(buffer, cursorManager)
+						}
+					case sdl.K_DELETE:
+						if byWord && editable(buffer) {
+							core.DeleteWordRight(buffer, cursorManager, subword)
 						}
-					case sdl.K_UP:
-						if primary.Row > 0 {
-							primary.Row--
-							primary.Col = 0 // Reset column to 0 when moving up
+					case sdl.K_UP, sdl.K_DOWN:
+						buffer.SealUndo()
+						delta := 1
+						if e.Keysym.Sym == sdl.K_UP {
+							delta = -1
 						}
-					case sdl.K_DOWN:
-						if primary.Row < len(strings.Split(buffer.Content, "\n"))-1 {
-							primary.Row++
-							primary.Col = 0 // Reset column to 0 when moving down
+						if e.Keysym.Mod&uint16(sdl.KMOD_ALT) != 0 && e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0 {
+							core.AddCursorVertical(buffer, cursorManager, textLayout(renderer, atlas), delta)
+							continue
 						}
+						core.MoveVertical(buffer, cursorManager, textLayout(renderer, atlas), delta, extend)
 					case sdl.K_LEFT:
-						if primary.Col > 0 {
-							primary.Col--
+						buffer.SealUndo()
+						if byWord {
+							core.MoveWordLeft(buffer, cursorManager, subword, extend)
+						} else {
+							core.MoveLeft(buffer, cursorManager, extend)
 						}
 					case sdl.K_RIGHT:
-						if primary.Col <= len([]rune(strings.Split(buffer.Content, "\n")[primary.Row]))-1 {
-							primary.Col++
+						buffer.SealUndo()
+						if byWord {
+							core.MoveWordRight(buffer, cursorManager, subword, extend)
+						} else {
+							core.MoveRight(buffer, cursorManager, extend)
 						}
+					case sdl.K_HOME:
+						buffer.SealUndo()
+						core.MoveLineStart(buffer, cursorManager, extend)
+					case sdl.K_END:
+						buffer.SealUndo()
+						core.MoveLineEnd(buffer, cursorManager, extend)
 					case sdl.K_RETURN:
-						buffer.SetContent(insertAtCursor(buffer.Content, "\n", primary.Row, primary.Col))
-						primary.Row++
-						primary.Col = 0
+						if editable(buffer) {
+							core.InsertAtCursors(buffer, cursorManager, "\n")
+						}
 					case sdl.K_TAB:
-						buffer.SetContent(insertAtCursor(buffer.Content, "    ", primary.Row, primary.Col)) // Insert 4 spaces for tab
-						primary.Col += 4
+						if e.Keysym.Mod&uint16(sdl.KMOD_CTRL) == 0 && editable(buffer) { // Ctrl+Tab switches documents
+							core.InsertAtCursors(buffer, cursorManager, "    ") // Insert 4 spaces for tab
+						}
 					}
 				}
-				if e.Keysym.Sym == sdl.K_ESCAPE && e.State == sdl.PRESSED {
-					running = false
+				if e.Keysym.Sym == sdl.K_ESCAPE && e.State == sdl.PRESSED && len(cursorManager.Cursors) > 1 {
+					buffer.SealUndo()
+					cursorManager.ClearSecondary()
+				} else if e.Keysym.Sym == sdl.K_ESCAPE && e.State == sdl.PRESSED {
+					running = !canQuit(scratch, e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0)
+				} else if e.Keysym.Sym == sdl.K_h && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
+					fmt.Println("Open history panel")
+					historyPanel.Open = true
+					historyPanel.Selected = len(buffer.History.Nodes()) - 1 - buffer.History.IndexOf(buffer.History.Current)
+				} else if e.Keysym.Sym == sdl.K_b && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
+					fmt.Println("Open bookmark list")
+					for _, d := range workspace.Documents {
+						session.SetBookmarks(d.Path, d.Buffer.Bookmarks())
+					}
+					bookmarkEntries = core.BookmarkList(buffer, doc.Path, session)
+					bookmarkPanel = listPanel{Open: true}
+				} else if e.Keysym.Sym == sdl.K_o && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
+					fmt.Println("Open document list")
+					documentPanel = listPanel{Open: true, Selected: workspace.Active}
+				} else if e.Keysym.Sym == sdl.K_TAB && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
+					keepScroll()
+					if e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0 {
+						workspace.Cycle(-1)
+					} else {
+						workspace.Cycle(1)
+					}
+					showCurrent()
+				} else if e.Keysym.Sym == sdl.K_s && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
+					if !editable(buffer) {
+						continue
+					}
+					if err := doc.Save(); err != nil {
+						showNotice(err.Error())
+					} else {
+						showNotice("Saved " + doc.Name())
+					}
+				} else if e.Keysym.Sym == sdl.K_w && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
+					discard := e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0
+					if err := closeDocument(workspace.Active, discard, session); err != nil {
+						showNotice(doc.Name() + " has unsaved changes; save with Ctrl+S or close with Ctrl+Shift+W to discard them")
+					} else if len(workspace.Documents) == 0 {
+						break mainLoop // Nothing left to show or edit
+					}
+				} else if e.Keysym.Sym == sdl.K_F2 && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
+					buffer.ToggleBookmark(primary.Row)
+				} else if e.Keysym.Sym == sdl.K_F2 && e.State == sdl.PRESSED {
+					var row int
+					var ok bool
+					if e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0 {
+						row, ok = buffer.PrevBookmark(primary.Row)
+					} else {
+						row, ok = buffer.NextBookmark(primary.Row)
+					}
+					if ok {
+						jumpToLine(buffer, row)
+					}
+				} else if e.Keysym.Sym == sdl.K_l && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0 && e.State == sdl.PRESSED {
+					if !editable(buffer) {
+						continue
+					}
+					if buffer.LineEnding() == core.LineEndingCRLF {
+						buffer.SetLineEnding(core.LineEndingLF)
+					} else {
+						buffer.SetLineEnding(core.LineEndingCRLF)
+					}
+					fmt.Println("Line endings converted to", buffer.LineEnding())
+				} else if e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.Keysym.Mod&uint16(sdl.KMOD_ALT) != 0 && isHistoryKey(e.Keysym.Sym) && e.State == sdl.PRESSED {
+					if !editable(buffer) {
+						continue
+					}
+					moved := false
+					switch {
+					case e.Keysym.Sym == sdl.K_z && e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0:
+						fmt.Println("Step forward in history")
+						moved = buffer.StepForward()
+					case e.Keysym.Sym == sdl.K_z:
+						fmt.Println("Step back in history")
+						moved = buffer.StepBack()
+					case e.Keysym.Sym == sdl.K_LEFTBRACKET:
+						fmt.Println("Previous history branch")
+						moved = buffer.SwitchBranch(-1)
+					case e.Keysym.Sym == sdl.K_RIGHTBRACKET:
+						fmt.Println("Next history branch")
+						moved = buffer.SwitchBranch(1)
+					case e.Keysym.Sym == sdl.K_MINUS:
+						fmt.Println("Go back", historyTimeStep)
+						moved = buffer.Earlier(historyTimeStep)
+					case e.Keysym.Sym == sdl.K_EQUALS:
+						fmt.Println("Go forward", historyTimeStep)
+						moved = buffer.Later(historyTimeStep)
+					}
+					if moved {
+						core.ClampCursor(buffer, cursorManager.GetPrimary())
+					}
 				} else if e.Keysym.Sym == sdl.K_EQUALS && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
 					zoom += 0.5
 					atlas.Destroy()
@@ -250,68 +558,92 @@ func main() {
 					}
 					atlas.Destroy()
 					atlas = NewGlyphAtlas(renderer, fontPath, int(float64(fontSize)*zoom))
+				} else if e.Keysym.Sym == sdl.K_c && e.Keysym.Mod&uint16(sdl.KMOD_GUI) != 0 && e.State == sdl.PRESSED {
+					fmt.Println("Copy to clipboard")
+					var parts []string
+					for i := range cursorManager.Cursors {
+						if text := cursorManager.GetSelectedText(i, buffer); text != "" {
+							parts = append(parts, text)
+						}
+					}
+					if text := strings.Join(parts, "\n"); text != "" {
+						if err := sdl.SetClipboardText(text); err != nil {
+							fmt.Println("Error setting clipboard text:", err)
+						}
+					}
 				} else if e.Keysym.Sym == sdl.K_v && e.Keysym.Mod&uint16(sdl.KMOD_GUI) != 0 && e.State == sdl.PRESSED {
 					fmt.Println("Paste from clipboard")
+					if !editable(buffer) {
+						continue
+					}
 					clipboardText, err := sdl.GetClipboardText()
 					if err != nil {
 						fmt.Println("Error getting clipboard text:", err)
 						continue
 					}
 					if clipboardText != "" {
-						if cursorManager.HasSelection() {
-							buffer.SetContent(DeleteSelectedText(buffer.Content, cursorManager))
-							cursorManager.ClearAllSelections()
-						}
-						old := strings.Split(buffer.Content, "\n")
-						ol := len([]rune(old[primary.Row]))
-						buffer.SetContent(insertAtCursor(buffer.Content, clipboardText, primary.Row, primary.Col))
-						lines := strings.Split(clipboardText, "\n")
-						ll := len(lines)
-						primary.Col = ol + len([]rune(lines[ll-1]))
-						primary.Row += ll - 1 // Move to the last line of the pasted text
+						// A paste is one change, including the selections it replaces
+						buffer.Begin()
+						clipboardText = strings.ReplaceAll(clipboardText, "\r\n", "\n")
+						core.InsertAtCursors(buffer, cursorManager, clipboardText) // Leaves the cursors after the pasted text
+						buffer.Commit()
+						buffer.SealUndo()
+					}
+				} else if e.Keysym.Sym == sdl.K_d && e.Keysym.Mod&uint16(sdl.KMOD_GUI) != 0 && e.State == sdl.PRESSED {
+					buffer.SealUndo()
+					if core.AddNextOccurrence(buffer, cursorManager) {
+						revealCursor = true
 					}
 				} else if e.Keysym.Sym == sdl.K_a && e.Keysym.Mod&uint16(sdl.KMOD_GUI) != 0 && e.State == sdl.PRESSED {
 					fmt.Println("Select all")
+					buffer.SealUndo()
+					cursorManager.ClearSecondary()
+					primary = cursorManager.GetPrimary()
 					cursorManager.ClearAllSelections()
 					primary.Selection.Active = true
 					primary.Selection.StartRow = 0
 					primary.Selection.StartCol = 0
-					primary.Selection.EndRow = len(strings.Split(buffer.Content, "\n")) - 1
-					primary.Selection.EndCol = len([]rune(strings.Split(buffer.Content, "\n")[primary.Selection.EndRow]))
+					primary.Selection.EndRow = buffer.LineCount() - 1
+					primary.Selection.EndCol = buffer.LineLen(primary.Selection.EndRow)
 					primary.Row = primary.Selection.EndRow
 					primary.Col = primary.Selection.EndCol
+				} else if (e.Keysym.Sym == sdl.K_z && e.Keysym.Mod&uint16(sdl.KMOD_GUI) != 0 && e.Keysym.Mod&uint16(sdl.KMOD_SHIFT) != 0 ||
+					e.Keysym.Sym == sdl.K_y && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0) && e.State == sdl.PRESSED {
+					fmt.Println("Redo last change")
+					if !editable(buffer) {
+						continue
+					}
+					if buffer.Redo() {
+						// Update cursor position after redo
+						core.ClampCursor(buffer, cursorManager.GetPrimary())
+					} else {
+						fmt.Println("No more redos available")
+					}
 				} else if e.Keysym.Sym == sdl.K_z && e.Keysym.Mod&uint16(sdl.KMOD_GUI) != 0 && e.State == sdl.PRESSED {
 					fmt.Println("Undo last change")
+					if !editable(buffer) {
+						continue
+					}
 					if buffer.Undo() {
 						// Update cursor position after undo
-						primary := cursorManager.GetPrimary()
-						lines := strings.Split(buffer.Content, "\n")
-						if primary.Row >= len(lines) {
-							primary.Row = len(lines) - 1
-						}
-						if primary.Col > len([]rune(lines[primary.Row])) {
-							primary.Col = len([]rune(lines[primary.Row]))
-						}
+						core.ClampCursor(buffer, cursorManager.GetPrimary())
 					} else {
 						fmt.Println("No more undos available")
 					}
 				} else if e.Keysym.Sym == sdl.K_e && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0 && e.State == sdl.PRESSED {
 					fmt.Println("move to end of line")
-					if primary.Row < len(strings.Split(buffer.Content, "\n")) {
-						line := strings.Split(buffer.Content, "\n")[primary.Row]
-						primary.Col = len([]rune(line))
-					}
+					buffer.SealUndo()
+					core.MoveLineEnd(buffer, cursorManager, false)
 				}
 			case *sdl.TextInputEvent:
 				input := e.GetText()
-				if input != "" {
-					primary := cursorManager.GetPrimary()
-					if cursorManager.HasSelection() {
-						buffer.SetContent(DeleteSelectedText(buffer.Content, cursorManager))
-						cursorManager.ClearAllSelections()
-					}
-					buffer.SetContent(insertAtCursor(buffer.Content, input, primary.Row, primary.Col))
-					primary.Col += len([]rune(input))
+				if large != nil {
+					showNotice("Read-only: large files can't be changed")
+					continue
+				}
+				if input != "" && !historyPanel.Open && !bookmarkPanel.Open && !documentPanel.Open && editable(buffer) {
+					// Typing over selections replaces them in a single change
+					core.InsertAtCursors(buffer, cursorManager, input)
 				}
 
 			}
@@ -324,7 +656,16 @@ func main() {
 		setColor(renderer, uiBackgroundColor)
 		renderer.Clear()
 
-		RenderTextWithSelection(renderer, atlas, buffer.Content, cursorManager)
+		doc := workspace.Current()
+		if large != nil {
+			RenderLargeFile(renderer, atlas, large)
+		} else {
+			RenderTextWithSelection(renderer, atlas, doc.Buffer, cursorManager)
+			if revealCursor {
+				revealCursor = false
+				scrollToCursor(renderer, atlas, cursorManager.GetPrimary())
+			}
+		}
 
 		frameCount++
 		currentTime := sdl.GetTicks64()
@@ -336,118 +677,286 @@ func main() {
 
 		// DrawTabs(renderer, atlas, []string{filePath})
 		DrawFPS(renderer, atlas, fps)
+		var status []string
+		if currentTime < noticeUntil {
+			status = append(status, notice)
+		}
+		if large != nil {
+			status = append(status, largeFileStatus(large)...)
+		} else {
+			name := doc.Name()
+			if doc.Dirty() {
+				name += "*"
+			}
+			status = append(status, name)
+			if doc.Buffer.ReadOnly() {
+				status = append(status, "Read-only")
+			}
+			status = append(status, doc.Buffer.Encoding.String(), doc.Buffer.LineEnding().String())
+		}
+		DrawStatusBar(renderer, atlas, status)
+		if historyPanel.Open {
+			DrawListPanel(renderer, atlas, historyLabels(doc.Buffer.History), historyPanel.Selected, "")
+		}
+		if bookmarkPanel.Open {
+			DrawListPanel(renderer, atlas, bookmarkLabels(bookmarkEntries), bookmarkPanel.Selected, "No bookmarks")
+		}
+		if documentPanel.Open {
+			DrawListPanel(renderer, atlas, documentLabels(workspace.Documents), documentPanel.Selected, "")
+		}
 
 		renderer.Present()
 		sdl.Delay(4)
 	}
-}
-
-func RenderTextWithSelection(renderer *sdl.Renderer, atlas *GlyphAtlas, text string, cm *CursorManager) {
-	y := int32(10) - scrollOffsetY
-	primary := cm.GetPrimary()
 
-	for row, line := range strings.Split(text, "\n") {
-		x := int32(10)
-		runes := []rune(line)
+	if large == nil {
+		for _, doc := range workspace.Documents {
+			if err := doc.SaveHistory(); err != nil {
+				fmt.Println("Error saving undo history:", err)
+			}
+			session.SetBookmarks(doc.Path, doc.Buffer.Bookmarks())
+		}
+		if err := session.Save(); err != nil {
+			fmt.Println("Error saving session:", err)
+		}
+	}
+}
 
-		chars := 0
+// lineLayouts returns the cached line layouts of buffer, laid out for the
+// window as it is now.
+func lineLayouts(renderer *sdl.Renderer, atlas *GlyphAtlas, buffer *core.Buffer) *core.LayoutCache {
+	layout := textLayout(renderer, atlas)
+	c := layouts[buffer]
+	if c == nil {
+		c = core.NewLayoutCache(buffer, layout)
+		layouts[buffer] = c
+	}
+	c.SetLayout(layout)
+	return c
+}
 
-		for i := 0; i < len(runes); i++ {
-			s := string(runes[i])
-			chars++
+// RenderTextWithSelection draws the lines of buffer that are on screen with
+// the selections and cursors of cm. Where the first of them is comes from the
+// line heights in the layout cache; nothing above or below it is laid out.
+func RenderTextWithSelection(renderer *sdl.Renderer, atlas *GlyphAtlas, buffer *core.Buffer, cm *core.CursorManager) {
+	_, rh, _ := renderer.GetOutputSize()
+	layout := textLayout(renderer, atlas)
+	lines := lineLayouts(renderer, atlas, buffer)
+	cw := charWidth(renderer, atlas)
+	lineHeight := int32(atlas.Size + atlas.Size/3)
+
+	top := int32(10) - scrollOffsetY // Where the text starts
+	first := lines.RowAt(int(-top / lineHeight))
+	y := top + int32(lines.Top(first))*lineHeight
+
+	bookmarks := buffer.Bookmarks()
+	for len(bookmarks) > 0 && bookmarks[0] < first {
+		bookmarks = bookmarks[1:] // Above the window
+	}
+	row := first
+	for ; row < buffer.LineCount() && y < rh; row++ {
+		x := textLeft(atlas)
+		if len(bookmarks) > 0 && bookmarks[0] == row {
+			DrawBookmarkMarker(renderer, atlas, y)
+			bookmarks = bookmarks[1:]
+		}
+		ll := lines.Line(row)
+		clusters := ll.Clusters
+		breaks := ll.Breaks // Columns at which the line wraps
+		col := 0            // Column of clusters[i] in runes
+
+		for i := 0; i < len(clusters); i++ {
+			s := clusters[i]
+			start := col
+			col += utf8.RuneCountInString(s)
+
+			if len(breaks) > 0 && start == breaks[0] {
+				x = textLeft(atlas)                   // Continue the line below
+				y += int32(atlas.Size + atlas.Size/3) // Move to next line
+				breaks = breaks[1:]
+			}
 
-			// Handle ligatures
-			if i < len(runes)-1 {
-				pair := string(runes[i]) + string(runes[i+1])
+			// Handle ligatures, unless the line wraps between the two halves
+			ligature := false
+			if i < len(clusters)-1 && (len(breaks) == 0 || breaks[0] != col) {
+				pair := clusters[i] + clusters[i+1]
 				if contains(ligatures, pair) {
 					s = pair
-					i++ // Skip next rune
+					ligature = true
+					col += utf8.RuneCountInString(clusters[i+1])
+					i++ // Skip next cluster
 				}
 			}
 
-			// Check if this character is selected
-			isSelected := IsCharacterSelected(row, i, primary.Selection)
+			// Tabs are blank up to the next tab stop
+			var tx *sdl.Texture
+			var w, h int32
+			if s == "\t" {
+				w = int32(layout.Width(s, int((x-textLeft(atlas))/cw))) * cw
+			} else if tx = atlas.GetTexture(s, renderer); tx != nil {
+				_, _, w, h, _ = tx.Query()
+			} else {
+				continue
+			}
 
 			// Render selection background
-			if isSelected {
-				tx := atlas.GetTexture(s, renderer)
-				if tx != nil {
-					_, _, w, _, _ := tx.Query()
-					selectionRect := sdl.Rect{X: x, Y: y, W: w, H: int32(atlas.Size + atlas.Size/3)}
-					renderer.SetDrawColor(173, 216, 230, 128) // Light blue selection
-					renderer.FillRect(&selectionRect)
-				}
+			if cm.IsSelected(row, start) {
+				selectionRect := sdl.Rect{X: x, Y: y, W: w, H: int32(atlas.Size + atlas.Size/3)}
+				renderer.SetDrawColor(173, 216, 230, 128) // Light blue selection
+				renderer.FillRect(&selectionRect)
 			}
 
-			tx := atlas.GetTexture(s, renderer)
-			if tx == nil {
-				continue
+			cm.SetRenderPos(row, start, x, y)
+			if ligature {
+				cm.SetRenderPos(row, start+1, x+w/2, y) // Between the two halves of a ligature
 			}
-			_, _, w, h, _ := tx.Query()
-
-			if x+w > rw-50 {
-				x = 10                                // Reset to start of line if it exceeds window width
-				y += int32(atlas.Size + atlas.Size/3) // Move to next line
+			if tx != nil {
+				renderer.Copy(tx, nil, &sdl.Rect{X: int32(x), Y: int32(y), W: w, H: h})
 			}
 
-			cm.SetRenderPos(row, i, x, y)
-			renderer.Copy(tx, nil, &sdl.Rect{X: int32(x), Y: int32(y), W: w, H: h})
-
-			// Render cursor(s)
-
 			x += w
 		}
 
-		cm.SetRenderPos(row, chars, x, y)
+		cm.SetRenderPos(row, col, x, y)
 
 		y += int32(atlas.Size + atlas.Size/3) // Move to next line
 	}
 
+	// Cursors off screen are still placed, so scrollToCursor can find them
+	for i := range cm.Cursors {
+		if c := &cm.Cursors[i]; c.Row < first || c.Row >= row {
+			vline, vcol := buffer.VisualCol(layout, c.Row, c.Col)
+			c.X, c.Y = textLeft(atlas)+int32(vcol)*cw, top+int32(lines.Top(c.Row)+vline)*lineHeight
+		}
+	}
+
 	RenderCursors(renderer, atlas, cm)
 }
 
-func insertAtCursor(text string, input string, row, col int) string {
-	lines := strings.Split(string(text), "\n")
+// RenderLargeFile draws only the rows of lf that fit in the window, starting
+// at largeFileTop. Long lines are cut off at the window edge instead of
+// wrapped, so the row on screen never depends on lines above it.
+func RenderLargeFile(renderer *sdl.Renderer, atlas *GlyphAtlas, lf *core.LargeFile) {
+	_, rh, _ := renderer.GetOutputSize()
+	lineHeight := int32(atlas.Size + atlas.Size/3)
 
-	if row < 0 || row >= len(lines) {
-		return string(text) // Invalid row
+	y := int32(10)
+	for row := largeFileTop; row < lf.LineCount() && y < rh; row++ {
+		x := int32(10)
+		for _, r := range lf.Line(row) {
+			if x > rw-50 {
+				break
+			}
+			tx := atlas.GetTexture(string(r), renderer)
+			if tx == nil {
+				continue
+			}
+			_, _, w, h, _ := tx.Query()
+			renderer.Copy(tx, nil, &sdl.Rect{X: x, Y: y, W: w, H: h})
+			x += w
+		}
+		y += lineHeight
 	}
+}
 
-	line := lines[row]
-	runes := []rune(line)
-
-	if col < 0 || col > len(runes) {
-		col = len(runes) // Adjust to end of line if col is out of bounds
+// largeFileStatus returns the status bar items for a large file, showing
+// indexing progress until every line is known.
+func largeFileStatus(lf *core.LargeFile) []string {
+	items := []string{"Read-only", lf.Encoding.String()}
+	switch {
+	case lf.Err() != nil:
+		items = append(items, "Indexing failed")
+	case lf.Indexing():
+		items = append(items, fmt.Sprintf("Indexing %d%%", int(lf.Progress()*100)))
+	default:
+		items = append(items, fmt.Sprintf("%d lines", lf.LineCount()))
 	}
+	return items
+}
 
-	lines[row] = string(runes[:col]) + string(input) + string(runes[col:])
-	return strings.Join(lines, "\n")
+// scrollLargeFile moves the first shown row by delta rows.
+func scrollLargeFile(lf *core.LargeFile, delta int) {
+	largeFileTop = max(min(largeFileTop+delta, lf.LineCount()-1), 0)
 }
 
-func deleteAtCursor(text string, row, col int) string {
-	lines := strings.Split(text, "\n")
+// handleLargeFileKey scrolls a large file with the arrow, page and home/end
+// keys.
+func handleLargeFileKey(e *sdl.KeyboardEvent, lf *core.LargeFile, renderer *sdl.Renderer, atlas *GlyphAtlas) {
+	_, rh, _ := renderer.GetOutputSize()
+	page := max(int(rh)/(atlas.Size+atlas.Size/3)-1, 1)
+	switch e.Keysym.Sym {
+	case sdl.K_UP:
+		scrollLargeFile(lf, -1)
+	case sdl.K_DOWN:
+		scrollLargeFile(lf, 1)
+	case sdl.K_PAGEUP:
+		scrollLargeFile(lf, -page)
+	case sdl.K_PAGEDOWN:
+		scrollLargeFile(lf, page)
+	case sdl.K_HOME:
+		largeFileTop = 0
+	case sdl.K_END:
+		largeFileTop = 0
+		scrollLargeFile(lf, lf.LineCount()-page)
+	}
+}
 
-	if row < 0 || row >= len(lines) {
-		return text // Invalid row
+// handleListPanelKey moves the selection of panel, which lists n rows, with
+// Up and Down, and closes it on Escape or Ctrl with shortcut. It reports
+// whether Return picked the selected row.
+func handleListPanelKey(e *sdl.KeyboardEvent, panel *listPanel, n int, shortcut sdl.Keycode) bool {
+	switch e.Keysym.Sym {
+	case sdl.K_UP:
+		panel.Selected = max(panel.Selected-1, 0)
+	case sdl.K_DOWN:
+		panel.Selected = max(min(panel.Selected+1, n-1), 0)
+	case sdl.K_RETURN:
+		return panel.Selected < n
+	}
+	if closesPanel(e, shortcut) {
+		panel.Open = false
 	}
+	return false
+}
 
-	line := lines[row]
-	runes := []rune(line)
+// closesPanel reports whether e closes a panel opened with Ctrl and sym:
+// Escape, or the same shortcut again. The letter on its own doesn't, since
+// the text input that follows it would then be typed into the document.
+func closesPanel(e *sdl.KeyboardEvent, sym sdl.Keycode) bool {
+	return e.Keysym.Sym == sdl.K_ESCAPE || e.Keysym.Sym == sym && e.Keysym.Mod&uint16(sdl.KMOD_CTRL) != 0
+}
 
-	if col < 0 || col > len(runes) {
-		return text // Invalid column
+// isHistoryKey reports whether sym moves through the undo history when
+// pressed with Ctrl+Alt.
+func isHistoryKey(sym sdl.Keycode) bool {
+	switch sym {
+	case sdl.K_z, sdl.K_LEFTBRACKET, sdl.K_RIGHTBRACKET, sdl.K_MINUS, sdl.K_EQUALS:
+		return true
 	}
+	return false
+}
 
-	if col == 0 {
-		if row > 0 {
-			lines[row-1] += string(runes) // Merge with previous line
-			lines = append(lines[:row], lines[row+1:]...)
-		}
-	} else {
-		lines[row] = string(runes[:col-1]) + string(runes[col:]) // Delete character at col
-	}
+// jumpToLine moves the cursor to the start of line row and scrolls it into
+// view.
+func jumpToLine(buffer *core.Buffer, row int) {
+	buffer.SealUndo()
+	cursorManager.ClearSecondary()
+	cursorManager.ClearAllSelections()
+	primary := cursorManager.GetPrimary()
+	primary.Row, primary.Col = row, 0
+	core.ClampCursor(buffer, primary)
+	revealCursor = true
+}
 
-	return strings.Join(lines, "\n")
+// scrollToCursor scrolls so that cursor, as placed by the last render, is on
+// screen.
+func scrollToCursor(renderer *sdl.Renderer, atlas *GlyphAtlas, cursor *core.Cursor) {
+	_, rh, _ := renderer.GetOutputSize()
+	lineHeight := int32(atlas.Size + atlas.Size/3)
+	if cursor.Y >= 0 && cursor.Y+lineHeight <= rh {
+		return
+	}
+	targetScrollOffsetY = max(float32(cursor.Y+scrollOffsetY-rh/3), 0)
 }
 
 func contains(slice []string, item string) bool {
@@ -459,49 +968,63 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-func GetRowColFromClick(x, y int32, sampleText string, atlas *GlyphAtlas, renderer *sdl.Renderer) (int, int) {
+func GetRowColFromClick(x, y int32, buffer *core.Buffer, atlas *GlyphAtlas, renderer *sdl.Renderer) (int, int) {
 
-	lines := strings.Split(sampleText, "\n")
-
-	curX := int32(10)
+	curX := textLeft(atlas)
 	curY := int32(10) // Starting Y position for the first line
+	layout := textLayout(renderer, atlas)
+	lines := lineLayouts(renderer, atlas, buffer)
+	cw := charWidth(renderer, atlas)
 
 	row, col := 0, 0
 
-	for i, line := range lines {
+	// Start at the row the click is on, found from the line heights alone
+	lineHeight := int32(atlas.Size + atlas.Size/3)
+	start := lines.RowAt(int((y - curY) / lineHeight))
+	curY += int32(lines.Top(start)) * lineHeight
+
+	for i := start; i < buffer.LineCount(); i++ {
 
 		row = i
 
-		runes := []rune(line)
+		ll := lines.Line(i)
+		clusters := ll.Clusters
+		breaks := ll.Breaks
 
 		// handle empty lines
-		if len(runes) == 0 {
+		if len(clusters) == 0 {
 			if curY <= y && curY+int32(atlas.Size+atlas.Size/3) > y {
 				fmt.Println("Empty line clicked at row:", row, "col:", col)
 				return row, 0 // Return column 0 for empty lines
 			}
 			curY += int32(atlas.Size + atlas.Size/3) // Move to next line
-			curX = int32(10)                         // Reset X for the next line
+			curX = textLeft(atlas)                   // Reset X for the next line
 			continue
 		}
 
-		for j, ch := range runes {
-			col = j
-			tx := atlas.GetTexture(string(ch), renderer)
-			if tx == nil {
-				continue
-			}
-
-			_, _, w, _, _ := tx.Query()
+		next := 0 // Column after the current cluster
+		for _, cluster := range clusters {
+			col = next
+			next += utf8.RuneCountInString(cluster)
 
-			if curX+w > rw-50 {
-				curX = int32(10)                         // Reset X for the next line
+			if len(breaks) > 0 && col == breaks[0] {
+				curX = textLeft(atlas)                   // Reset X for the next line
 				curY += int32(atlas.Size + atlas.Size/3) // Move to next line
+				breaks = breaks[1:]
+			}
+
+			var w int32
+			if cluster == "\t" {
+				w = int32(layout.Width(cluster, int((curX-textLeft(atlas))/cw))) * cw
+			} else if tx := atlas.GetTexture(cluster, renderer); tx != nil {
+				_, _, w, _, _ = tx.Query()
+			} else {
+				continue
 			}
 
 			if curX <= x && curX+w > x &&
 				curY <= y && curY+int32(atlas.Size+atlas.Size/3) > y {
-				fmt.Println("x:", x, "curX:", curX, "y:", y, "curY:", curY, "w:", w, "col:", col, "string(ch):", string(ch))
+				fmt.Println("x:", x, "curX:", curX, "y:", y, "curY:", curY, "w:", w, "col:", col, "cluster:", cluster)
 				return row, col
 			}
 
@@ -511,11 +1034,11 @@ func GetRowColFromClick(x, y int32, sampleText string, atlas *GlyphAtlas, render
 		// handle row click but no col
 		if curY <= y && curY+int32(atlas.Size+atlas.Size/3) > y {
 			fmt.Println("Row clicked at row:", row, "col:", col)
-			return row, col + 1 // Return column 0 for row click
+			return row, next // Return the end of the line for row click
 		}
 
 		curY += int32(atlas.Size + atlas.Size/3) // Move to next line
-		curX = int32(10)                         // Reset X for the next line
+		curX = textLeft(atlas)                   // Reset X for the next line
 	}
 
 	fmt.Println("No valid position found for click at x:", x, "y:", y)
@@ -7,7 +7,10 @@ import "C"
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/mariownyou/go-text-editor/core"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
@@ -18,6 +21,7 @@ const (
 var (
 	uiBackgroundColor   = []uint8{247, 247, 247, 1}
 	tabsBackgroundColor = []uint8{222, 222, 222, 1}
+	bookmarkColor       = []uint8{66, 133, 244, 255}
 )
 
 func setColor(renderer *sdl.Renderer, color []uint8) {
@@ -47,6 +51,119 @@ func DrawFPS(renderer *sdl.Renderer, atlas *GlyphAtlas, fps int) {
 	}
 }
 
+// DrawStatusBar draws short pieces of information about the buffer, such as
+// its line ending, in the bottom right corner.
+func DrawStatusBar(renderer *sdl.Renderer, atlas *GlyphAtlas, items []string) {
+	statusTexture := atlas.GetTexture(strings.Join(items, "  "), renderer)
+	if statusTexture != nil {
+		_, _, w, h, _ := statusTexture.Query()
+		rw, rh, _ := renderer.GetOutputSize()
+		rect := sdl.Rect{X: rw - w - 20, Y: rh - h - 10, W: w + 20, H: h + 10}
+		setColor(renderer, tabsBackgroundColor)
+		renderer.FillRect(&rect)
+		renderer.Copy(statusTexture, nil, &sdl.Rect{X: rw - w - 10, Y: rh - h - 5, W: w, H: h})
+	}
+}
+
+// gutterWidth returns the width of the gutter left of the text, where
+// bookmarks are marked.
+func gutterWidth(atlas *GlyphAtlas) int32 {
+	return int32(atlas.Size)
+}
+
+// textLeft returns the x position at which lines of text start.
+func textLeft(atlas *GlyphAtlas) int32 {
+	return 10 + gutterWidth(atlas)
+}
+
+// charWidth returns the width of one column of text. The font is
+// monospaced, so every ordinary character is this wide.
+func charWidth(renderer *sdl.Renderer, atlas *GlyphAtlas) int32 {
+	if tx := atlas.GetTexture("M", renderer); tx != nil {
+		_, _, w, _, _ := tx.Query()
+		return w
+	}
+	return int32(atlas.Size / 2)
+}
+
+// textLayout returns how lines are laid out in the window, wrapping them
+// before the right margin.
+func textLayout(renderer *sdl.Renderer, atlas *GlyphAtlas) core.Layout {
+	return core.Layout{
+		TabWidth:  core.DefaultTabWidth,
+		WrapWidth: int(max((rw-50-textLeft(atlas))/charWidth(renderer, atlas), 1)),
+	}
+}
+
+// DrawBookmarkMarker draws the gutter marker of a bookmarked line whose top
+// is at y.
+func DrawBookmarkMarker(renderer *sdl.Renderer, atlas *GlyphAtlas, y int32) {
+	size := int32(atlas.Size / 2)
+	lineHeight := int32(atlas.Size + atlas.Size/3)
+	setColor(renderer, bookmarkColor)
+	renderer.FillRect(&sdl.Rect{X: 10 + (gutterWidth(atlas)-size)/2, Y: y + (lineHeight-size)/2, W: size, H: size})
+}
+
+// listPanel is the state of a list shown on the right side of the window,
+// such as the undo history or the open documents.
+type listPanel struct {
+	Open     bool
+	Selected int // Row of the list that is highlighted
+}
+
+// DrawListPanel draws labels as a list on the right side of the window with
+// the selected row highlighted, or empty when there are no labels.
+func DrawListPanel(renderer *sdl.Renderer, atlas *GlyphAtlas, labels []string, selected int, empty string) {
+	rw, rh, _ := renderer.GetOutputSize()
+	lineHeight := int32(atlas.Size + atlas.Size/3)
+	panelW := rw * 2 / 5
+	panelX := rw - panelW
+
+	setColor(renderer, tabsBackgroundColor)
+	renderer.FillRect(&sdl.Rect{X: panelX, Y: 0, W: panelW, H: rh})
+
+	if len(labels) == 0 {
+		labels, selected = []string{empty}, -1
+	}
+	y := int32(10)
+	for i, label := range labels {
+		if y >= rh {
+			break
+		}
+		if i == selected {
+			setColor(renderer, uiBackgroundColor)
+			renderer.FillRect(&sdl.Rect{X: panelX, Y: y, W: panelW, H: lineHeight})
+		}
+		if tx := atlas.GetTexture(label, renderer); tx != nil {
+			_, _, w, h, _ := tx.Query()
+			renderer.Copy(tx, nil, &sdl.Rect{X: panelX + 10, Y: y, W: w, H: h})
+		}
+		y += lineHeight
+	}
+}
+
+// bookmarkLabels lists bookmarks as file, line and the text on it.
+func bookmarkLabels(entries []core.BookmarkEntry) []string {
+	labels := make([]string, len(entries))
+	for i, entry := range entries {
+		labels[i] = fmt.Sprintf("%s:%d  %s", filepath.Base(entry.Path), entry.Row+1, strings.TrimSpace(entry.Text))
+	}
+	return labels
+}
+
+// documentLabels lists open documents by name, marking those with unsaved
+// changes.
+func documentLabels(docs []*core.Document) []string {
+	labels := make([]string, len(docs))
+	for i, doc := range docs {
+		labels[i] = doc.Name()
+		if doc.Dirty() {
+			labels[i] += "*"
+		}
+	}
+	return labels
+}
+
 func DrawTabs(renderer *sdl.Renderer, atlas *GlyphAtlas, tabs []string) {
 	tabX := int32(10)
 	tabY := int32(10)
@@ -73,3 +190,58 @@ func DrawTabs(renderer *sdl.Renderer, atlas *GlyphAtlas, tabs []string) {
 	setColor(renderer, tabsBackgroundColor)
 	renderer.FillRect(&sdl.Rect{X: tabX - 10, Y: 0, W: rw, H: h + 10})
 }
+
+// historyLabels lists the undo tree newest state first, so row i of the list
+// is node len(nodes)-1-i. Nested branches are indented and the current state
+// is marked with "*".
+func historyLabels(tree *core.UndoTree) []string {
+	nodes := tree.Nodes()
+	labels := make([]string, 0, len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		marker := " "
+		if node == tree.Current {
+			marker = "*"
+		}
+		labels = append(labels, fmt.Sprintf("%s%s%d  %s  %s", marker, strings.Repeat("  ", branchDepth(node)),
+			node.Seq, node.Time.Format("15:04:05"), describeEntry(node.Entry)))
+	}
+	return labels
+}
+
+// branchDepth counts how many times the path from the root to node leaves
+// the first branch, which is how far the node is indented in the panel.
+func branchDepth(node *core.UndoNode) int {
+	depth := 0
+	for n := node; n.Parent != nil; n = n.Parent {
+		if n.Parent.Children[0] != n {
+			depth++
+		}
+	}
+	return depth
+}
+
+// describeEntry summarizes an undo step as the number of bytes it inserted
+// and deleted, or as a line ending conversion, which changes no text.
+func describeEntry(entry core.UndoEntry) string {
+	inserted, deleted := 0, 0
+	for _, edit := range entry.Edits {
+		inserted += len(edit.Inserted)
+		deleted += len(edit.Deleted)
+	}
+	if inserted == 0 && deleted == 0 {
+		return "line endings"
+	}
+	return fmt.Sprintf("+%d -%d", inserted, deleted)
+}
+
+func RenderCursor(renderer *sdl.Renderer, atlas *GlyphAtlas, c *core.Cursor) {
+	renderer.SetDrawColor(0, 0, 0, 255)
+	renderer.FillRect(&sdl.Rect{X: c.X, Y: c.Y, W: 4, H: int32(atlas.Size)}) // Assuming height of cursor is 16
+}
+
+func RenderCursors(renderer *sdl.Renderer, atlas *GlyphAtlas, cm *core.CursorManager) {
+	for _, cursor := range cm.Cursors {
+		RenderCursor(renderer, atlas, &cursor)
+	}
+}